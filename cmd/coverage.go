@@ -0,0 +1,65 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"github.com/fercevik729/Wave/openapi"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+)
+
+var (
+	coverageSpec   string
+	coverageReport string
+)
+
+// addCoverageFlags adds the flags reporting the OpenAPI coverage of a run to the command
+func addCoverageFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&coverageSpec, "openapi", "", "OpenAPI 3 specification to report the coverage of")
+	cmd.Flags().StringVar(&coverageReport, "openapi-report", "", "file the OpenAPI coverage report is "+
+		"exported to as JSON")
+}
+
+// startCoverage records the responses of the run if a specification is set and returns a function reporting its
+// coverage once the run completes
+func startCoverage(opts *driver.Options) func() {
+	if coverageSpec == "" {
+		return func() {}
+	}
+	spec, err := openapi.Load(coverageSpec)
+	if err != nil {
+		log.Fatalf("Couldn't load %s: %v\n", coverageSpec, err)
+	}
+	coverage, err := openapi.NewCoverage(spec)
+	if err != nil {
+		log.Fatalf("Couldn't read the operations of %s: %v\n", coverageSpec, err)
+	}
+	opts.OnResponse = func(req *driver.Request, resp *http.Response) {
+		coverage.Record(req.Method, req.Base+req.Endpoint, resp.StatusCode)
+	}
+
+	return func() {
+		report := coverage.Report()
+		if err := report.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		if coverageReport == "" {
+			return
+		}
+		data, err := report.JSON()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(coverageReport, data, 0644); err != nil {
+			log.Fatalf("Couldn't export the coverage report: %v\n", err)
+		}
+		fmt.Printf("Exported the coverage report to %s\n", coverageReport)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"github.com/fercevik729/Wave/importer"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// curlCmd represents the import curl command
+var curlCmd = &cobra.Command{
+	Use:   "curl [command]",
+	Short: "Imports a request from a curl command",
+	Long: `Imports a request from a curl command passed as a single quoted argument, as the arguments following --,
+or on stdin if no argument is given. The method, URL, headers, data, basic auth and cookies of the command are
+imported. Since curl doesn't know the expected status code, the success code of the request is 200.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			imported *importer.Imported
+			warnings []string
+			err      error
+		)
+		switch len(args) {
+		case 0:
+			command, readErr := ioutil.ReadAll(os.Stdin)
+			if readErr != nil {
+				log.Fatalf("Couldn't read the curl command: %v\n", readErr)
+			}
+			imported, warnings, err = importer.Curl(string(command))
+		case 1:
+			imported, warnings, err = importer.Curl(args[0])
+		default:
+			// The shell already split the command into words
+			imported, warnings, err = importer.CurlArgs(args)
+		}
+		if err != nil {
+			log.Fatalf("Couldn't import the curl command: %v\n", err)
+		}
+		appendImported([]*importer.Imported{imported}, warnings, importDataDir)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(curlCmd)
+}
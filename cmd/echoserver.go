@@ -0,0 +1,45 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/fercevik729/Wave/echoserver"
+	"github.com/spf13/cobra"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+var (
+	echoPort  int
+	echoToken string
+)
+
+// echoServerCmd represents the echo-server command
+var echoServerCmd = &cobra.Command{
+	Use:   "echo-server",
+	Short: "Starts a local server to test requests and Wave's throughput against",
+	Long: `Starts a local HTTP server with the endpoints:
+/echo: responds with the method, path, query, headers and body of the request as JSON
+/delay/{duration}: echoes the request after waiting for the duration, such as 250ms
+/status/{code}: echoes the request with the status code
+/auth/token: responds with {"token": token}, for authentication requests
+/auth/protected: echoes the request if it has an "Authorization: Bearer token" header, responds with 401 otherwise
+/bytes/{n}: responds with a JSON object of exactly n bytes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr := ":" + strconv.Itoa(echoPort)
+		fmt.Printf("Echo server listening on %s...\n", addr)
+		log.Fatal(http.ListenAndServe(addr, echoserver.Handler(&echoserver.Options{Token: echoToken})))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(echoServerCmd)
+
+	echoServerCmd.Flags().IntVarP(&echoPort, "port", "p", 8090, "port the echo server listens on")
+	echoServerCmd.Flags().StringVar(&echoToken, "token", echoserver.DefaultToken, "token handed out by "+
+		"/auth/token and required by /auth/protected")
+}
@@ -0,0 +1,26 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var generateDataDir string
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generates requests from API specifications",
+	Long: `Generates requests from API specifications, appending them to the requests file. Example bodies and response
+schemas are written to data files in the data directory.`,
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	generateCmd.PersistentFlags().StringVar(&generateDataDir, "data-dir", "./data", "directory the example "+
+		"bodies and response schemas are written to")
+}
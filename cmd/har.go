@@ -0,0 +1,49 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"github.com/fercevik729/Wave/importer"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"log"
+)
+
+var harOptions importer.HAROptions
+
+// harCmd represents the import har command
+var harCmd = &cobra.Command{
+	Use:   "har [file]",
+	Short: "Imports the requests of a HAR file recorded in the browser",
+	Long: `Imports the requests of an HTTP Archive exported from the devtools of a browser in the order they were sent,
+with their headers, query, body and observed status as the success code. Entries can be filtered by domain and response
+content type, and the original timing between requests can be replayed by whirl as think time.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			log.Fatalf("%v Make sure your filepath is correct.\n", err)
+		}
+		imported, warnings, err := importer.HAR(data, harOptions)
+		if err != nil {
+			log.Fatalf("Couldn't import %s: %v\n", args[0], err)
+		}
+		if len(imported) == 0 {
+			log.Fatalf("No entries of %s matched the filters\n", args[0])
+		}
+		appendImported(imported, warnings, importDataDir)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(harCmd)
+
+	harCmd.Flags().StringSliceVar(&harOptions.Domains, "domain", nil, "only imports requests sent to these "+
+		"domains and their subdomains")
+	harCmd.Flags().StringSliceVar(&harOptions.ContentTypes, "content-type", nil, "only imports requests whose "+
+		"response content type contains one of these, such as json")
+	harCmd.Flags().BoolVar(&harOptions.ThinkTime, "think-time", false, "replays the time between the requests "+
+		"as think time")
+}
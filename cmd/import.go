@@ -0,0 +1,44 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/fercevik729/Wave/importer"
+	"github.com/spf13/cobra"
+	"log"
+)
+
+var importDataDir string
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Imports requests from other tools into the requests file",
+	Long: `Imports requests from other tools, appending them to the requests file under the names suggested by the
+tool, such as the Postman item name, or the next free request-N name if it's empty or already taken. Request bodies
+are written to data files in the data directory.`,
+}
+
+// appendImported appends the imported requests to the requests file and reports them along with any warnings
+func appendImported(imported []*importer.Imported, warnings []string, dataDir string) {
+	for _, warning := range warnings {
+		log.Printf("WARNING: %s\n", warning)
+	}
+	names, err := importer.Append(requestsFile, dataDir, imported)
+	if err != nil {
+		log.Fatalf("Couldn't append the requests to %s: %v\n", requestsFile, err)
+	}
+	for _, name := range names {
+		fmt.Printf("Added %s to %s\n", name, requestsFile)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.PersistentFlags().StringVar(&importDataDir, "data-dir", "./data", "directory the request bodies "+
+		"are written to")
+}
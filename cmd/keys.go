@@ -0,0 +1,98 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	runKeyFile string
+	runPassEnv string
+)
+
+// addKeyFlags adds the flags giving the passphrase of an encrypted credentials file to a command running requests
+func addKeyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&runKeyFile, "key-file", "", "key file with the passphrase of an encrypted credentials file")
+	cmd.Flags().StringVar(&runPassEnv, "pass-env", "", "environment variable holding the passphrase of an "+
+		"encrypted credentials file")
+}
+
+// runKey returns a KeySource reading the passphrase from the key file, the environment variable or an interactive
+// prompt, in that order. The passphrase is only read once
+func runKey() driver.KeySource {
+	var once sync.Once
+	var key string
+	var err error
+	return func() (string, error) {
+		once.Do(func() {
+			switch {
+			case runKeyFile != "":
+				key, err = readKeyFile(runKeyFile)
+			case runPassEnv != "":
+				key, err = envKey(runPassEnv)
+			default:
+				key, err = promptKey("Passphrase: ")
+			}
+		})
+		return key, err
+	}
+}
+
+// promptKey reads a passphrase from the terminal without echoing it
+func promptKey(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no terminal to prompt for the passphrase, use --key-file or --pass-env")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	byteKey, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(byteKey), nil
+}
+
+// readKeyFile reads a passphrase from a key file, trimming the trailing newlines added by editors
+func readKeyFile(keyfile string) (string, error) {
+	byteKey, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(byteKey), "\r\n"), nil
+}
+
+// envKey reads a passphrase from an environment variable
+func envKey(name string) (string, error) {
+	key := os.Getenv(name)
+	if key == "" {
+		return "", fmt.Errorf("environment variable %s is empty", name)
+	}
+	return key, nil
+}
+
+// readKeyStdin reads a passphrase from the first line of stdin
+func readKeyStdin() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	key := strings.TrimRight(line, "\r\n")
+	if key == "" {
+		return "", errors.New("no passphrase on stdin")
+	}
+	return key, nil
+}
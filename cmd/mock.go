@@ -0,0 +1,53 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/fercevik729/Wave/mock"
+	"github.com/spf13/cobra"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	mockPort      int
+	mockLatency   time.Duration
+	mockErrorRate float64
+)
+
+// mockCmd represents the mock command
+var mockCmd = &cobra.Command{
+	Use:   "mock",
+	Short: "Serves the requests from the specified file as a mock API",
+	Long: `Starts an HTTP server answering every method and endpoint of the requests file with its success code and the
+contents of its expect file. Endpoints using {id} notation match any id, and the path of the base URL is kept, so
+requests to https://api.example.com/v1 are served under /v1. Authentication requests without an expect file respond
+with a token where the request captures it from.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if mockErrorRate < 0 || mockErrorRate > 1 {
+			log.Fatalf("The error rate should be between 0 and 1, got %v\n", mockErrorRate)
+		}
+		server := mock.New(requestsFile, runKey(), &mock.Options{
+			Latency:   mockLatency,
+			ErrorRate: mockErrorRate,
+		})
+		addr := ":" + strconv.Itoa(mockPort)
+		fmt.Printf("Serving the requests of %s on %s...\n", requestsFile, addr)
+		log.Fatal(http.ListenAndServe(addr, server))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mockCmd)
+	addKeyFlags(mockCmd)
+
+	mockCmd.Flags().IntVarP(&mockPort, "port", "p", 8080, "port the mock API listens on")
+	mockCmd.Flags().DurationVar(&mockLatency, "latency", 0, "delay added before every response, such as 100ms")
+	mockCmd.Flags().Float64Var(&mockErrorRate, "error-rate", 0, "fraction of requests, between 0 and 1, "+
+		"that get a 500 response")
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"github.com/fercevik729/Wave/openapi"
+	"github.com/spf13/cobra"
+	"log"
+)
+
+var openapiBase string
+
+// openapiCmd represents the generate openapi command
+var openapiCmd = &cobra.Command{
+	Use:   "openapi [spec]",
+	Short: "Generates a request for every operation of an OpenAPI 3 specification",
+	Long: `Generates a request for every operation of an OpenAPI 3 specification in YAML or JSON. The last path parameter
+of an operation becomes the {id} placeholder, with its example as the id-range, and the other path parameters are
+replaced with their examples. Example request bodies are written to data files, the first documented 2xx status becomes
+the success code, and JSON response schemas are written to schema files that responses are validated against.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec, err := openapi.Load(args[0])
+		if err != nil {
+			log.Fatalf("Couldn't load %s: %v\n", args[0], err)
+		}
+		imported, warnings, err := openapi.Generate(spec, openapiBase)
+		if err != nil {
+			log.Fatalf("Couldn't generate requests from %s: %v\n", args[0], err)
+		}
+		if len(imported) == 0 {
+			log.Fatalf("No operations found in %s\n", args[0])
+		}
+		appendImported(imported, warnings, generateDataDir)
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(openapiCmd)
+
+	openapiCmd.Flags().StringVar(&openapiBase, "base", "", "base URL of the API, defaults to the first server "+
+		"of the specification")
+}
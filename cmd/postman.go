@@ -0,0 +1,53 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"github.com/fercevik729/Wave/importer"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"log"
+)
+
+var postmanEnvironment string
+
+// postmanCmd represents the import postman command
+var postmanCmd = &cobra.Command{
+	Use:   "postman [collection]",
+	Short: "Imports the requests of a Postman collection",
+	Long: `Imports the requests of a Postman v2.1 collection, naming them after their folders. Postman {{variables}} are
+replaced with the values of the collection or of an exported environment, except secret variables and variables named
+like tokens, passwords or API keys which are left as is. Auth settings are converted to auth strategies whose secrets
+belong in the credentials file, and bodies are written to data files. Pre-request and test scripts aren't supported.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		collection, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			log.Fatalf("%v Make sure your filepath is correct.\n", err)
+		}
+		var environment []byte
+		if postmanEnvironment != "" {
+			environment, err = ioutil.ReadFile(postmanEnvironment)
+			if err != nil {
+				log.Fatalf("%v Make sure your filepath is correct.\n", err)
+			}
+		}
+		imported, warnings, err := importer.Postman(collection, environment)
+		if err != nil {
+			log.Fatalf("Couldn't import %s: %v\n", args[0], err)
+		}
+		if len(imported) == 0 {
+			log.Fatalf("No requests found in %s\n", args[0])
+		}
+		appendImported(imported, warnings, importDataDir)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(postmanCmd)
+
+	postmanCmd.Flags().StringVarP(&postmanEnvironment, "environment", "e", "", "exported Postman environment "+
+		"with the values of the variables")
+}
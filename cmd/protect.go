@@ -5,51 +5,112 @@ Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fercevik729/Wave/driver"
 	"github.com/spf13/cobra"
-	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 )
 
-var encrypt bool
+var (
+	encrypt bool
+	backup  bool
+)
 
 // protectCmd represents the protect command
 var protectCmd = &cobra.Command{
-	Use:   "protect",
+	Use:   "protect [files]",
 	Short: "Encrypts and decrypts the credentials file",
+	Long: `Encrypts and decrypts the credentials file. Other files, such as data files, expect files or the private
+key of a TLS client certificate, can be protected by passing their paths or glob patterns as arguments.
+
+The passphrase is read from the -p, --keyfile, --pass-env or --pass-stdin flags. If none of them is given it is
+prompted for without echoing it, twice when encrypting. Files are replaced atomically and only readable by their
+owner.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		files, err := expandPaths(args)
+		if err != nil {
+			log.Fatalf("Couldn't expand the file paths: %v\n", err)
+		}
 		key := cmd.PersistentFlags().Lookup("pass").Value.String()
 		keyfile := cmd.PersistentFlags().Lookup("keyfile").Value.String()
-		if key == "" && keyfile != "" {
-			byteKey, e := ioutil.ReadFile(keyfile)
-			if e != nil {
-				log.Fatalf("%v Make sure your filepath is correct.\n", e)
+		passEnv := cmd.PersistentFlags().Lookup("pass-env").Value.String()
+		passStdin, _ := cmd.PersistentFlags().GetBool("pass-stdin")
+		switch {
+		case key != "":
+		case keyfile != "":
+			key, err = readKeyFile(keyfile)
+			if err != nil {
+				log.Fatalf("%v Make sure your filepath is correct.\n", err)
 			}
-			key = string(byteKey)
+		case passEnv != "":
+			key, err = envKey(passEnv)
+		case passStdin:
+			key, err = readKeyStdin()
+		default:
+			// Ask twice when encrypting so that a typo doesn't lock the file
+			key, err = promptKey("Passphrase: ")
+			if err == nil && encrypt {
+				var confirmation string
+				confirmation, err = promptKey("Confirm passphrase: ")
+				if err == nil && confirmation != key {
+					err = errors.New("passphrases don't match")
+				}
+			}
+		}
+		if err != nil {
+			log.Fatalf("Couldn't read the passphrase: %v\n", err)
+		}
+		if key == "" {
+			fmt.Println("Please specify a passphrase")
+			return
 		}
-		if key == "" || len(key) < 16 {
-			fmt.Println("Please specify a passphrase that is 16 characters long")
+
+		failed := false
+		for _, file := range files {
 			// Call encrypt function
-		} else if encrypt {
-			fmt.Println("Encrypting credentials file...")
-			err := driver.Encrypt(credentialsFile, key)
-			if err != nil {
-				log.Fatalf("Couldn't encrypt file, err: %v\n", err)
+			if encrypt {
+				fmt.Printf("Encrypting %s...\n", file)
+				err = driver.EncryptWithBackup(file, key, backup)
+			} else {
+				fmt.Printf("Decrypting %s\n", file)
+				// Call decrypt function
+				err = driver.DecryptWithBackup(file, key, backup)
 			}
-			fmt.Println("Process complete")
-		} else if !encrypt {
-			fmt.Println("Decrypting credentials file")
-			// Call decrypt function
-			err := driver.Decrypt(credentialsFile, key)
 			if err != nil {
-				log.Fatalf("Couldn't decrypt file, err: %v\n", err)
+				log.Printf("Couldn't protect %s, err: %v\n", file, err)
+				failed = true
 			}
-			fmt.Println("Process complete")
 		}
+		if failed {
+			os.Exit(1)
+		}
+		fmt.Println("Process complete")
 	},
 }
 
+// expandPaths expands the glob patterns in the arguments into file paths, defaulting to the credentials file
+func expandPaths(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{credentialsFile}, nil
+	}
+	files := make([]string, 0, len(args))
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		// Keep paths without matches so that the missing file is reported
+		if len(matches) == 0 {
+			matches = []string{arg}
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
 func init() {
 	rootCmd.AddCommand(protectCmd)
 
@@ -59,4 +120,8 @@ func init() {
 	protectCmd.PersistentFlags().StringP("pass", "p", "", "Passphrase to encrypt/decrypt "+
 		"credentials file with.")
 	protectCmd.PersistentFlags().StringP("keyfile", "k", "", "Key file with passphrase")
+	protectCmd.PersistentFlags().String("pass-env", "", "Environment variable holding the passphrase")
+	protectCmd.PersistentFlags().Bool("pass-stdin", false, "Reads the passphrase from stdin")
+	protectCmd.PersistentFlags().BoolVar(&backup, "backup", false, "Keeps the previous version of each file "+
+		"with a .bak extension. When encrypting the backup holds the plaintext")
 }
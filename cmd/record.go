@@ -0,0 +1,62 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/fercevik729/Wave/importer"
+	"github.com/spf13/cobra"
+	"log"
+	"net/http"
+)
+
+var (
+	recordListen       string
+	recordUpstream     string
+	recordDataDir      string
+	recordContentTypes []string
+)
+
+// recordCmd represents the record command
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Records the traffic sent through a local reverse proxy as requests",
+	Long: `Runs a local reverse proxy forwarding the traffic of an app or browser to the upstream API and appends every
+exchange to the requests file as it happens. Responses are streamed to the client as they arrive. Request bodies are
+written to data files, JSON responses of up to 1 MiB to expect files and the observed status becomes the success code.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := importer.RecordOptions{ContentTypes: recordContentTypes}
+		recorder, err := importer.NewRecorder(recordUpstream, opts, func(imported *importer.Imported,
+			warnings []string) {
+			for _, warning := range warnings {
+				log.Printf("WARNING: %s\n", warning)
+			}
+			names, err := importer.Append(requestsFile, recordDataDir, []*importer.Imported{imported})
+			if err != nil {
+				log.Printf("Couldn't append %s %s to %s: %v\n", imported.Request.Method, imported.Request.Endpoint,
+					requestsFile, err)
+				return
+			}
+			fmt.Printf("Recorded %s %s as %s\n", imported.Request.Method, imported.Request.Endpoint, names[0])
+		})
+		if err != nil {
+			log.Fatalf("Check the upstream URL: %v\n", err)
+		}
+		fmt.Printf("Recording traffic to %s on %s...\n", recordUpstream, recordListen)
+		log.Fatal(http.ListenAndServe(recordListen, recorder))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recordCmd)
+
+	recordCmd.Flags().StringVar(&recordListen, "listen", ":8089", "address the proxy listens on")
+	recordCmd.Flags().StringVar(&recordUpstream, "upstream", "", "base URL of the API the traffic is forwarded to")
+	recordCmd.Flags().StringVar(&recordDataDir, "data-dir", "./data", "directory the request bodies and expected "+
+		"responses are written to")
+	recordCmd.Flags().StringSliceVar(&recordContentTypes, "content-type", nil, "only records the responses whose "+
+		"content type contains one of these")
+	_ = recordCmd.MarkFlagRequired("upstream")
+}
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/fercevik729/Wave/driver"
 	"github.com/spf13/cobra"
 	"os"
 	"strconv"
@@ -20,6 +21,8 @@ var (
 	logFile         string
 	iterations      int
 	verbose         bool
+	proxy           string
+	resolve         []string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -60,6 +63,17 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&logFile, "output", "o", "", "file to write output to")
 	rootCmd.PersistentFlags().StringVarP(&credentialsFile, "credentials", "c", "./data/cred.yaml", "yaml file containing credentials")
 	rootCmd.PersistentFlags().IntVarP(&iterations, "iterations", "i", 10, "describes how many sets of requests to run")
+	rootCmd.PersistentFlags().StringVar(&proxy, "proxy", "", "HTTP(S) proxy to send requests through")
+	rootCmd.PersistentFlags().StringArrayVar(&resolve, "resolve", nil, "pins a host and port to an address, "+
+		"formatted as host:port:addr")
+}
+
+// runOptions returns the driver options set by the flags
+func runOptions() *driver.Options {
+	return &driver.Options{
+		Proxy:   proxy,
+		Resolve: resolve,
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
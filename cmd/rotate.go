@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+)
+
+var (
+	oldKeyFile string
+	newKeyFile string
+)
+
+// rotateCmd represents the protect rotate command
+var rotateCmd = &cobra.Command{
+	Use:   "rotate [files]",
+	Short: "Re-encrypts the credentials file with a new passphrase",
+	Long: `Re-encrypts the credentials file, or the encrypted files and glob patterns passed as arguments, with the
+passphrase in the new key file. The plaintext is never written to disk and the file is replaced atomically, keeping its permissions.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := expandPaths(args)
+		if err != nil {
+			log.Fatalf("Couldn't expand the file paths: %v\n", err)
+		}
+		if oldKeyFile == "" || newKeyFile == "" {
+			log.Fatalln("Please specify both --old-key-file and --new-key-file")
+		}
+		oldKey, err := readKeyFile(oldKeyFile)
+		if err != nil {
+			log.Fatalf("%v Make sure your filepath is correct.\n", err)
+		}
+		newKey, err := readKeyFile(newKeyFile)
+		if err != nil {
+			log.Fatalf("%v Make sure your filepath is correct.\n", err)
+		}
+
+		failed := false
+		for _, file := range files {
+			fmt.Printf("Rotating the passphrase of %s...\n", file)
+			if err := driver.RotateWithBackup(file, oldKey, newKey, backup); err != nil {
+				log.Printf("Couldn't rotate the passphrase of %s, err: %v\n", file, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		fmt.Println("Process complete")
+	},
+}
+
+func init() {
+	protectCmd.AddCommand(rotateCmd)
+
+	rotateCmd.Flags().StringVar(&oldKeyFile, "old-key-file", "", "Key file with the current passphrase")
+	rotateCmd.Flags().StringVar(&newKeyFile, "new-key-file", "", "Key file with the new passphrase")
+}
@@ -10,18 +10,29 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var loginPerUser bool
+
 // splashCmd represents the wave command
 var splashCmd = &cobra.Command{
 	Use:   "splash",
 	Short: "Concurrently runs HTTP requests from the specified file for i sets",
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Starting splash...")
-		requests, keychain := driver.New(requestsFile, credentialsFile)
-		driver.Splash(iterations, requests, verbose, logFile, keychain)
+		requests, keychain := driver.NewWithKey(requestsFile, credentialsFile, runKey())
+		opts := runOptions()
+		opts.LoginPerUser = loginPerUser
+		report := startCoverage(opts)
+		driver.Splash(iterations, requests, verbose, logFile, keychain, opts)
+		report()
 		fmt.Println("Process completed")
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(splashCmd)
+	addKeyFlags(splashCmd)
+	addCoverageFlags(splashCmd)
+
+	splashCmd.Flags().BoolVar(&loginPerUser, "login-per-user", false, "runs the authentication requests once "+
+		"for every set of requests instead of once before the load starts")
 }
@@ -10,18 +10,31 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var updateSnapshots bool
+
 // whirlCmd represents the whirl command
 var whirlCmd = &cobra.Command{
 	Use:   "whirl",
 	Short: "Sequentially runs the HTTP requests from the specified file for i cycles",
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Starting whirl...")
-		requests, keychain := driver.New(requestsFile, credentialsFile)
-		driver.Whirlpool(iterations, requests, verbose, logFile, keychain)
+		key := runKey()
+		requests, keychain := driver.NewWithKey(requestsFile, credentialsFile, key)
+		opts := runOptions()
+		opts.UpdateSnapshots = updateSnapshots
+		opts.Key = key
+		report := startCoverage(opts)
+		driver.Whirlpool(iterations, requests, verbose, logFile, keychain, opts)
+		report()
 		fmt.Println("Process completed")
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(whirlCmd)
+	addKeyFlags(whirlCmd)
+	addCoverageFlags(whirlCmd)
+
+	whirlCmd.Flags().BoolVar(&updateSnapshots, "update-snapshots", false, "writes the response bodies to the "+
+		"expect files and snapshots of the requests instead of comparing them")
 }
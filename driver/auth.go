@@ -0,0 +1,141 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuthConfig selects the strategy used to authenticate a request, drawing its secrets from the credentials file:
+// Type: one of basic, bearer, api-key, hmac or sigv4
+// Header: header carrying the API key or HMAC signature, defaults to X-API-Key and X-Signature respectively
+// Query: query parameter carrying the API key instead of a header
+// Prefix: text put before the API key or signature in the header, such as "ApiKey "
+// TimestampHeader: header carrying the timestamp of an HMAC signature, defaults to X-Timestamp
+// KeyIDHeader: header carrying the HMAC key id if the credentials have one, defaults to X-Key-Id
+// Region: AWS region of sigv4 signatures, overrides the region of the credentials
+// Service: AWS service of sigv4 signatures, overrides the service of the credentials
+type AuthConfig struct {
+	Type            string `yaml:"type,omitempty"`
+	Header          string `yaml:"header,omitempty"`
+	Query           string `yaml:"query,omitempty"`
+	Prefix          string `yaml:"prefix,omitempty"`
+	TimestampHeader string `yaml:"timestamp-header,omitempty"`
+	KeyIDHeader     string `yaml:"key-id-header,omitempty"`
+	Region          string `yaml:"region,omitempty"`
+	Service         string `yaml:"service,omitempty"`
+}
+
+// authenticator authenticates a prepared request whose body is final using the credentials of a KeyChain
+type authenticator func(req *http.Request, body []byte, conf *AuthConfig, keys *KeyChain) error
+
+// authenticators maps the auth types to their strategies
+var authenticators = map[string]authenticator{
+	"basic":   basicAuth,
+	"bearer":  bearerAuth,
+	"api-key": apiKeyAuth,
+	"hmac":    hmacAuth,
+	"sigv4":   sigV4Auth,
+}
+
+// authenticate applies the auth strategy of the request, falling back to the is-auth and r-token fields
+func (r *Request) authenticate(req *http.Request, keys *KeyChain) error {
+	if r.Auth != nil {
+		auth, ok := authenticators[r.Auth.Type]
+		if !ok {
+			return fmt.Errorf("unknown auth type %q", r.Auth.Type)
+		}
+		return auth(req, r.body.Bytes(), r.Auth, keys)
+	}
+	if r.IsAuth {
+		return basicAuth(req, nil, nil, keys)
+	} else if r.RToken {
+		return bearerAuth(req, nil, nil, keys)
+	}
+	return nil
+}
+
+// usesToken reports whether the request is authorized with the token of the KeyChain
+func (r *Request) usesToken() bool {
+	if r.Auth != nil {
+		return r.Auth.Type == "bearer"
+	}
+	return r.RToken && !r.IsAuth
+}
+
+// basicAuth sets the username and password of the KeyChain
+func basicAuth(req *http.Request, _ []byte, _ *AuthConfig, keys *KeyChain) error {
+	req.SetBasicAuth(keys.User, keys.Pass)
+	return nil
+}
+
+// bearerAuth sets the token of the KeyChain as the Authorization header
+func bearerAuth(req *http.Request, _ []byte, _ *AuthConfig, keys *KeyChain) error {
+	token, err := keys.authorization()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", token)
+	return nil
+}
+
+// apiKeyAuth sets the API key of the KeyChain in a header or query parameter
+func apiKeyAuth(req *http.Request, _ []byte, conf *AuthConfig, keys *KeyChain) error {
+	if keys.APIKey == "" {
+		return fmt.Errorf("api-key auth requires an api-key in the credentials file")
+	}
+	if conf.Query != "" {
+		query := req.URL.Query()
+		query.Set(conf.Query, keys.APIKey)
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+	header := conf.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	req.Header.Set(header, conf.Prefix+keys.APIKey)
+	return nil
+}
+
+// hmacAuth signs the method, path, timestamp and body of the request with HMAC-SHA256, the hex encoded signature is
+// computed over those four values joined by newlines
+func hmacAuth(req *http.Request, body []byte, conf *AuthConfig, keys *KeyChain) error {
+	if keys.HMACSecret == "" {
+		return fmt.Errorf("hmac auth requires an hmac-secret in the credentials file")
+	}
+	header, timestampHeader, keyIDHeader := conf.Header, conf.TimestampHeader, conf.KeyIDHeader
+	if header == "" {
+		header = "X-Signature"
+	}
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+	if keyIDHeader == "" {
+		keyIDHeader = "X-Key-Id"
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(timestampHeader, timestamp)
+	if keys.HMACKeyID != "" {
+		req.Header.Set(keyIDHeader, keys.HMACKeyID)
+	}
+	req.Header.Set(header, conf.Prefix+hmacSignature(keys.HMACSecret, req.Method, req.URL.RequestURI(), timestamp, body))
+	return nil
+}
+
+// hmacSignature returns the hex encoded HMAC-SHA256 signature of the method, path, timestamp and body
+func hmacSignature(secret, method, path, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "k3y" && r.URL.Query().Get("api_key") != "k3y" &&
+			r.Header.Get("Authorization") != "ApiKey k3y" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	reqs := []*Request{
+		{Method: "GET", Base: server.URL, Endpoint: "/a", SuccessCode: 200, Auth: &AuthConfig{Type: "api-key"}},
+		{Method: "GET", Base: server.URL, Endpoint: "/b?page=2", SuccessCode: 200,
+			Auth: &AuthConfig{Type: "api-key", Query: "api_key"}},
+		{Method: "GET", Base: server.URL, Endpoint: "/c", SuccessCode: 200,
+			Auth: &AuthConfig{Type: "api-key", Header: "Authorization", Prefix: "ApiKey "}},
+	}
+	actual := Whirlpool(1, reqs, false, "", &KeyChain{APIKey: "k3y"}, nil)
+	if actual != 3 {
+		t.Errorf("Expected 3 successes, but got %d successes", actual)
+	}
+
+	// Missing secrets are reported
+	if _, err := reqs[0].prepareRequest(&KeyChain{}); err == nil {
+		t.Errorf("Expected an error for a missing api-key")
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		expected := hmacSignature("s3cret", r.Method, r.URL.RequestURI(), r.Header.Get("X-Timestamp"), body)
+		if r.Header.Get("X-Signature") != expected || r.Header.Get("X-Key-Id") != "partner-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	req := &Request{Method: "POST", Base: server.URL, Endpoint: "/orders?dry=true", SuccessCode: 200,
+		ContentType: "application/json", Auth: &AuthConfig{Type: "hmac"}}
+	req.body = *bytes.NewBufferString(`{"item": "coffee"}`)
+	actual := Whirlpool(2, []*Request{req}, false, "", &KeyChain{HMACKeyID: "partner-1", HMACSecret: "s3cret"}, nil)
+	if actual != 2 {
+		t.Errorf("Expected 2 successes, but got %d successes", actual)
+	}
+
+	// Signature computed with openssl dgst -sha256 -hmac key
+	signature := hmacSignature("key", "GET", "/path", "1650000000", nil)
+	if signature != "c62f712aaa5a084e17e0268ea2ee14565a13212ae1ab02f215707c4c7bf93a67" {
+		t.Errorf("Expected the hex encoded HMAC-SHA256 signature, but got %s", signature)
+	}
+}
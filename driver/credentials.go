@@ -0,0 +1,245 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// defaultProfile is the name of the profile used by requests that don't select one
+const defaultProfile = "default"
+
+// parseCredentials unmarshals a credentials file holding either a single set of credentials or named profiles such
+// as admin, reader and service. The returned KeyChain is the default profile and holds every named profile. Encrypted
+// users files are decrypted with the passphrase of the KeySource
+func parseCredentials(data []byte, key KeySource) (*KeyChain, error) {
+	var top map[string]interface{}
+	err := yaml.Unmarshal(data, &top)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single set of credentials uses the KeyChain fields at the top level
+	if len(top) == 0 || hasKeyChainField(top) {
+		keys := &KeyChain{}
+		err = yaml.Unmarshal(data, keys)
+		if err != nil {
+			return nil, err
+		}
+		return keys, keys.loadAllUsers(key)
+	}
+
+	var profiles map[string]*KeyChain
+	err = yaml.Unmarshal(data, &profiles)
+	if err != nil {
+		return nil, err
+	}
+	for name, profile := range profiles {
+		if profile == nil {
+			return nil, fmt.Errorf("credential profile %s is empty", name)
+		}
+	}
+	keys := profiles[defaultProfile]
+	if keys == nil {
+		keys = &KeyChain{}
+	}
+	keys.Profiles = profiles
+	return keys, keys.loadAllUsers(key)
+}
+
+// loadAllUsers loads the credential pools of the KeyChain and its profiles
+func (c *KeyChain) loadAllUsers(key KeySource) error {
+	for _, keys := range c.all() {
+		if keys.UsersFile == "" {
+			continue
+		}
+		err := keys.loadUsers(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasKeyChainField reports whether any of the keys is a field of the KeyChain
+func hasKeyChainField(top map[string]interface{}) bool {
+	t := reflect.TypeOf(KeyChain{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if _, ok := top[tag]; ok && tag != "" && tag != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// profile returns the named credential profile, or the KeyChain itself if no name is given
+func (c *KeyChain) profile(name string) (*KeyChain, error) {
+	if name == "" {
+		return c, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("credential profile %q not found, available profiles: %v", name, names)
+	}
+	return profile, nil
+}
+
+// all returns the KeyChain and every named profile
+func (c *KeyChain) all() []*KeyChain {
+	chains := []*KeyChain{c}
+	for _, profile := range c.Profiles {
+		if profile != c {
+			chains = append(chains, profile)
+		}
+	}
+	return chains
+}
+
+// clone returns a copy of the KeyChain and its profiles so that tokens captured by one virtual user aren't seen by
+// the others
+func (c *KeyChain) clone() *KeyChain {
+	keys := *c
+	if c.Profiles != nil {
+		keys.Profiles = make(map[string]*KeyChain, len(c.Profiles))
+		for name, profile := range c.Profiles {
+			if profile == c {
+				keys.Profiles[name] = &keys
+				continue
+			}
+			p := *profile
+			keys.Profiles[name] = &p
+		}
+	}
+	return &keys
+}
+
+// loadUsers reads the credential pool of a KeyChain from a CSV file of username, password and token columns, with an
+// optional header row, or a YAML list of user, pass and token entries. The file may be encrypted with the protect
+// command
+func (c *KeyChain) loadUsers(key KeySource) error {
+	data, err := ioutil.ReadFile(c.UsersFile)
+	if err != nil {
+		return err
+	}
+	data = decryptFile(c.UsersFile, data, key)
+
+	if strings.ToLower(filepath.Ext(c.UsersFile)) == ".csv" {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return err
+		}
+		// Columns are positional unless there is a header row naming them
+		columns := map[string]int{"user": 0, "pass": 1, "token": 2}
+		if len(rows) > 0 && isUsersHeader(rows[0]) {
+			columns = map[string]int{"user": -1, "pass": -1, "token": -1}
+			for i, name := range rows[0] {
+				switch strings.ToLower(name) {
+				case "user", "username":
+					columns["user"] = i
+				case "pass", "password":
+					columns["pass"] = i
+				case "token":
+					columns["token"] = i
+				}
+			}
+			rows = rows[1:]
+		}
+		column := func(row []string, name string) string {
+			if i := columns[name]; i >= 0 && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+		for _, row := range rows {
+			c.users = append(c.users, KeyChain{
+				User:  column(row, "user"),
+				Pass:  column(row, "pass"),
+				Token: column(row, "token"),
+			})
+		}
+	} else {
+		err = yaml.Unmarshal(data, &c.users)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(c.users) == 0 {
+		return fmt.Errorf("users file %s has no credentials", c.UsersFile)
+	}
+	return nil
+}
+
+// isUsersHeader reports whether a CSV row is a header row
+func isUsersHeader(row []string) bool {
+	for _, name := range row {
+		switch strings.ToLower(name) {
+		case "user", "username", "pass", "password", "token":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// perUser reports whether every virtual user needs its own copy of the KeyChain because the KeyChain or one of its
+// profiles checks out credentials from a users file or mints tokens
+func (c *KeyChain) perUser() bool {
+	for _, keys := range c.all() {
+		if len(keys.users) > 0 || keys.JWT != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// forUser returns a copy of the KeyChain whose credential pools have each checked out the credentials of the
+// virtual user numbered vu, starting at 0, out of a total number of virtual users
+func (c *KeyChain) forUser(vu, total int) (*KeyChain, error) {
+	keys := c.clone()
+	for _, profile := range keys.all() {
+		profile.vu = vu + 1
+		if len(profile.users) == 0 {
+			continue
+		}
+		var user KeyChain
+		switch profile.Assign {
+		case "", "sequential":
+			user = profile.users[vu%len(profile.users)]
+		case "random":
+			user = profile.users[rand.Intn(len(profile.users))]
+		case "unique":
+			// Every virtual user gets its own credentials until the pool is exhausted, the rest reuse them
+			if vu == len(profile.users) {
+				log.Printf("WARNING: users file %s has %d credentials for %d virtual users, the credentials are "+
+					"reused from now on\n", profile.UsersFile, len(profile.users), total)
+			}
+			user = profile.users[vu%len(profile.users)]
+		default:
+			return nil, fmt.Errorf("unknown assign policy %q, use sequential, random or unique", profile.Assign)
+		}
+		profile.User, profile.Pass, profile.Token = user.User, user.Pass, user.Token
+	}
+	return keys, nil
+}
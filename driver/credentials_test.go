@@ -0,0 +1,147 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const profilesYAML = `admin:
+  token: "Bearer admin-token"
+reader:
+  user: "reader@example.com"
+  pass: "password1234"
+  token: "Bearer reader-token"
+`
+
+func TestCredentialProfiles(t *testing.T) {
+	credFile := filepath.Join(t.TempDir(), "cred.yaml")
+	if err := ioutil.WriteFile(credFile, []byte(profilesYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The protect command handles files with profiles, which are decrypted in memory
+	if err := Encrypt(credFile, "mysecretpassword"); err != nil {
+		t.Fatal(err)
+	}
+	chain := readCredentials(credFile, func() (string, error) {
+		return "mysecretpassword", nil
+	})
+	if strongText, _ := ioutil.ReadFile(credFile); !isEncrypted(strongText) {
+		t.Errorf("Expected the credentials file to stay encrypted")
+	}
+	if len(chain.Profiles) != 2 || chain.Profiles["reader"].User != "reader@example.com" {
+		t.Fatalf("Expected the admin and reader profiles, but got %v", chain.Profiles)
+	}
+	if _, err := chain.profile("service"); err == nil {
+		t.Errorf("Expected an error for a missing profile")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+r.URL.Path[1:]+"-token" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	reqs := []*Request{
+		{Method: "GET", Base: server.URL, Endpoint: "/admin", SuccessCode: 200, RToken: true, Credentials: "admin"},
+		{Method: "GET", Base: server.URL, Endpoint: "/reader", SuccessCode: 200, RToken: true, Credentials: "reader"},
+		{Method: "GET", Base: server.URL, Endpoint: "/admin", SuccessCode: 403, RToken: true, Credentials: "reader"},
+	}
+	actual := Whirlpool(2, reqs, false, "", chain, nil)
+	if actual != 6 {
+		t.Errorf("Expected 6 successes, but got %d successes", actual)
+	}
+}
+
+func TestSingleCredentials(t *testing.T) {
+	credentials := "user: \"admin\"\noauth2:\n  token-url: \"http://localhost/token\"\n"
+	chain, err := parseCredentials([]byte(credentials), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.User != "admin" || chain.OAuth2 == nil || chain.Profiles != nil {
+		t.Errorf("Expected a single set of credentials, but got %v", chain)
+	}
+}
+
+func TestUsersFile(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "users.csv")
+	csvData := "username,password,token\nann,pw1,Bearer ann\nbob,pw2,Bearer bob\ncat,pw3,Bearer cat\n"
+	if err := ioutil.WriteFile(csvFile, []byte(csvData), 0600); err != nil {
+		t.Fatal(err)
+	}
+	yamlFile := filepath.Join(dir, "users.yaml")
+	yamlData := "- user: \"dan\"\n  pass: \"pw4\"\n- user: \"eve\"\n  pass: \"pw5\"\n"
+	if err := ioutil.WriteFile(yamlFile, []byte(yamlData), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every virtual user of a splash checks out distinct credentials
+	chain, err := parseCredentials([]byte("users-file: \""+csvFile+"\"\nassign: \"unique\"\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := safeSeen{tokens: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen.Lock()
+		seen.tokens[r.Header.Get("Authorization")]++
+		seen.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	reqs := []*Request{{Method: "GET", Base: server.URL, Endpoint: "/me", SuccessCode: 200, RToken: true}}
+	Splash(3, reqs, false, "", chain, nil)
+	if len(seen.tokens) != 3 || seen.tokens["Bearer ann"] != 1 || seen.tokens["Bearer cat"] != 1 {
+		t.Errorf("Expected every user to send one request, but got %v", seen.tokens)
+	}
+	// The credentials are reused once they're exhausted
+	userChain, err := chain.forUser(3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userChain.User != "ann" {
+		t.Errorf("Expected the fourth virtual user to reuse ann, but got %s", userChain.User)
+	}
+
+	// Sequential assignment wraps around
+	chain, err = parseCredentials([]byte("reader:\n  users-file: \""+yamlFile+"\"\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userChain, err = chain.forUser(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userChain.Profiles["reader"].User != "dan" || chain.Profiles["reader"].User != "" {
+		t.Errorf("Expected the third virtual user to check out dan, but got %s", userChain.Profiles["reader"].User)
+	}
+
+	// Users files may be encrypted with the protect command
+	if err := Encrypt(yamlFile, "mysecretpassword"); err != nil {
+		t.Fatal(err)
+	}
+	key := func() (string, error) { return "mysecretpassword", nil }
+	chain, err = parseCredentials([]byte("users-file: \""+yamlFile+"\"\n"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userChain, err = chain.forUser(1, 2); err != nil || userChain.User != "eve" {
+		t.Errorf("Expected the encrypted users file to be decrypted, but got %v and %v", userChain, err)
+	}
+}
+
+// safeSeen counts the tokens received by a test server
+type safeSeen struct {
+	sync.Mutex
+	tokens map[string]int
+}
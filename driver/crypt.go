@@ -5,130 +5,395 @@ Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
 package driver
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"golang.org/x/crypto/scrypt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
-	"strings"
+	"path/filepath"
+	"runtime"
 )
 
+// KeyError is returned when no passphrase is given
 type KeyError struct{}
 
 func (k *KeyError) Error() string {
-	return "Key length is too short"
+	return "Passphrase is empty"
 }
 
-// Encrypt encrypts the contents of a file using a 16 byte long key
-// It overwrites the contents of the file at the filepath
+var (
+	// ErrAlreadyEncrypted is returned when encrypting a file that is already encrypted
+	ErrAlreadyEncrypted = errors.New("file is already encrypted")
+	// ErrNotEncrypted is returned when decrypting a file that isn't encrypted
+	ErrNotEncrypted = errors.New("file isn't encrypted")
+	// ErrWrongPassphrase is returned when the passphrase doesn't match the encrypted contents
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted file")
+)
+
+// Encrypted files start with a self describing header:
+// magic "WAVE" (4 bytes), format version (1 byte), KDF id (1 byte), scrypt log2(N), r and p (1 byte each),
+// salt length (1 byte), salt, nonce length (1 byte), nonce
+// The header is authenticated along with the AES-256-GCM cipher text that follows it. The format starts at version 1,
+// a new version has to keep decoding the files of the previous ones
+var magic = []byte("WAVE")
+
+const (
+	formatVersion = 1
+	kdfScrypt     = 1
+	scryptLogN    = 15
+	scryptR       = 8
+	scryptP       = 1
+	saltSize      = 16
+	derivedKeyLen = 32
+	maxScryptLogN = 22
+)
+
+// header holds the format version and key derivation parameters of an encrypted file
+type header struct {
+	version byte
+	kdf     byte
+	logN    byte
+	r       byte
+	p       byte
+	salt    []byte
+	nonce   []byte
+}
+
+// secretPerm is the permission of files written by Encrypt and Decrypt, which hold secret material either way
+const secretPerm = 0600
+
+// Encrypt encrypts the contents of a file using a key derived from a passphrase of any length
+// It atomically replaces the file at the filepath
 func Encrypt(filepath string, key string) error {
+	return EncryptWithBackup(filepath, key, false)
+}
 
-	// Check the length
-	if len(key) < 16 {
+// EncryptWithBackup encrypts the contents of a file like Encrypt, keeping the previous version of the file at the
+// filepath with a .bak extension if backup is true
+func EncryptWithBackup(filepath string, key string, backup bool) error {
+
+	// Check the passphrase
+	if key == "" {
 		return &KeyError{}
 	}
 	// Read file contents
-	f, err := os.Open(filepath)
-	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}(f)
+	weakText, err := ioutil.ReadFile(filepath)
 	if err != nil {
 		return err
 	}
-	weakText, err := ioutil.ReadFile(filepath)
-	weakTextStr := string(weakText)
-	if !strings.Contains(weakTextStr, "user") || !strings.Contains(weakTextStr, "pass") || !strings.Contains(weakTextStr, "token") {
-		fmt.Println("Credentials file is already encrypted. Exiting...")
-		return nil
+	if isEncrypted(weakText) {
+		return ErrAlreadyEncrypted
 	}
-	if err != nil {
-		return err
+	if _, ok := decryptLegacy(weakText, key); ok {
+		return ErrAlreadyEncrypted
 	}
 
-	// Create new cipher
-	c, err := aes.NewCipher([]byte(key))
+	strongText, err := encryptBytes(weakText, key)
 	if err != nil {
 		return err
 	}
 
-	// Encrypt text
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return err
-	}
-	nonce := make([]byte, gcm.NonceSize())
+	// Output the text
+	return replaceFile(filepath, weakText, strongText, secretPerm, backup)
+
+}
+
+// Decrypt decrypts the contents of a file using the passphrase it was encrypted with
+// It atomically replaces the file at the filepath
+func Decrypt(filepath string, key string) error {
+	return DecryptWithBackup(filepath, key, false)
+}
 
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+// DecryptWithBackup decrypts the contents of a file like Decrypt, keeping the previous version of the file at the
+// filepath with a .bak extension if backup is true
+func DecryptWithBackup(filepath string, key string, backup bool) error {
+
+	// Check the passphrase
+	if key == "" {
+		return &KeyError{}
+	}
+	// Read file contents
+	strongText, err := ioutil.ReadFile(filepath)
+	if err != nil {
 		return err
 	}
-	strongText := gcm.Seal(nonce, nonce, weakText, nil)
-
-	// Output the text
-	err = ioutil.WriteFile(filepath, strongText, 0777)
+	weakText, err := decryptFileBytes(strongText, key)
 	if err != nil {
 		return err
 	}
+	// Output the text
+	return replaceFile(filepath, strongText, weakText, secretPerm, backup)
+}
 
-	return nil
-
+// Rotate re-encrypts a file encrypted with the old passphrase using the new passphrase
+// The plaintext is never written to disk and the file is replaced atomically, keeping its permissions
+func Rotate(filepath string, oldKey string, newKey string) error {
+	return RotateWithBackup(filepath, oldKey, newKey, false)
 }
 
-// Decrypt decrypts the contents of a file using a 16 byte long key
-// It overwrites the contents of the file at the filepath
-func Decrypt(filepath string, key string) error {
+// RotateWithBackup re-encrypts a file like Rotate, keeping the version encrypted with the old passphrase at the
+// filepath with a .bak extension if backup is true
+func RotateWithBackup(filepath string, oldKey string, newKey string, backup bool) error {
 
-	// Check the length
-	if len(key) < 16 {
+	// Check the passphrases
+	if oldKey == "" || newKey == "" {
 		return &KeyError{}
 	}
 	// Read file contents
-	f, err := os.Open(filepath)
-	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}(f)
+	strongText, err := ioutil.ReadFile(filepath)
 	if err != nil {
 		return err
 	}
-	strongText, err := ioutil.ReadFile(filepath)
+	weakText, err := decryptFileBytes(strongText, oldKey)
 	if err != nil {
 		return err
 	}
-
-	// Create cipher
-	c, err := aes.NewCipher([]byte(key))
+	rotated, err := encryptBytes(weakText, newKey)
 	if err != nil {
 		return err
 	}
+	// Output the text
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return err
+	}
+	return replaceFile(filepath, strongText, rotated, info.Mode().Perm(), backup)
+}
+
+// replaceFile atomically replaces the contents of the file with the name, first writing the previous contents to a
+// .bak file if backup is true
+func replaceFile(name string, previous []byte, data []byte, perm os.FileMode, backup bool) error {
+	if backup {
+		if err := writeFileAtomic(name+".bak", previous, secretPerm); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(name, data, perm)
+}
 
-	// Decrypt the file contents
-	gcm, err := cipher.NewGCM(c)
+// writeFileAtomic writes the data to a temporary file in the same directory, syncs it to disk, renames it over the
+// file with the name and syncs the directory, so that a crash never leaves a partially written or lost file behind
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
 	if err != nil {
 		return err
 	}
+	// Clean up the temporary file unless it was renamed
+	defer os.Remove(tmp.Name())
 
-	nonceSize := gcm.NonceSize()
-	if len(strongText) < nonceSize {
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
 		return err
 	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
 
-	nonce, strongText := strongText[:nonceSize], strongText[nonceSize:]
-	weakText, err := gcm.Open(nil, nonce, strongText, nil)
+// syncDir syncs a directory to disk so that a rename in it survives a crash. Windows doesn't support syncing
+// directories and makes renames durable on its own
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
 	if err != nil {
 		return err
 	}
-	// Output the text
-	err = ioutil.WriteFile(filepath, weakText, 0777)
-	if err != nil {
+	if err := d.Sync(); err != nil {
+		d.Close()
 		return err
 	}
-	return nil
+	return d.Close()
+}
+
+// IsEncrypted reports whether the file at the filepath is encrypted with the header written by Encrypt
+func IsEncrypted(filepath string) (bool, error) {
+	contents, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return false, err
+	}
+	return isEncrypted(contents), nil
+}
+
+// encryptBytes encrypts a slice of bytes with AES-256-GCM using a key derived from the passphrase with scrypt and
+// prepends the header
+func encryptBytes(weakText []byte, passphrase string) ([]byte, error) {
+	h := &header{
+		version: formatVersion,
+		kdf:     kdfScrypt,
+		logN:    scryptLogN,
+		r:       scryptR,
+		p:       scryptP,
+		salt:    make([]byte, saltSize),
+	}
+	if _, err := io.ReadFull(rand.Reader, h.salt); err != nil {
+		return nil, err
+	}
+	key, err := h.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	h.nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, h.nonce); err != nil {
+		return nil, err
+	}
+
+	encoded := h.encode()
+	return gcm.Seal(encoded, h.nonce, weakText, encoded), nil
+}
+
+// decryptFileBytes decrypts the contents of a file encrypted by encryptBytes or by older versions of Wave
+func decryptFileBytes(strongText []byte, passphrase string) ([]byte, error) {
+	if isEncrypted(strongText) {
+		return decryptBytes(strongText, passphrase)
+	}
+	if weakText, ok := decryptLegacy(strongText, passphrase); ok {
+		return weakText, nil
+	}
+	return nil, ErrNotEncrypted
+}
+
+// decryptBytes decrypts a slice of bytes produced by encryptBytes
+func decryptBytes(strongText []byte, passphrase string) ([]byte, error) {
+	if !isEncrypted(strongText) {
+		return nil, ErrNotEncrypted
+	}
+
+	h, n, err := decodeHeader(strongText)
+	if err != nil {
+		return nil, err
+	}
+	key, err := h.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d", len(h.nonce))
+	}
+	weakText, err := gcm.Open(nil, h.nonce, strongText[n:], strongText[:n])
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return weakText, nil
+}
+
+// decryptLegacy decrypts files encrypted by older versions of Wave, which have no header and used the raw 16, 24 or
+// 32 character passphrase as the AES key. Such files can only be told apart from plaintext by decrypting them
+func decryptLegacy(strongText []byte, passphrase string) ([]byte, bool) {
+	switch len(passphrase) {
+	case 16, 24, 32:
+		if weakText, err := openSealed(strongText, []byte(passphrase)); err == nil {
+			return weakText, true
+		}
+	}
+	return nil, false
+}
+
+// encode returns the binary form of the header
+func (h *header) encode() []byte {
+	encoded := append([]byte{}, magic...)
+	encoded = append(encoded, h.version, h.kdf, h.logN, h.r, h.p, byte(len(h.salt)))
+	encoded = append(encoded, h.salt...)
+	encoded = append(encoded, byte(len(h.nonce)))
+	return append(encoded, h.nonce...)
+}
+
+// decodeHeader parses the header at the start of the encrypted contents and returns it along with its length
+func decodeHeader(strongText []byte) (*header, int, error) {
+	short := errors.New("encrypted file header is truncated")
+	n := len(magic)
+	if len(strongText) < n+6 {
+		return nil, 0, short
+	}
+	h := &header{
+		version: strongText[n],
+		kdf:     strongText[n+1],
+		logN:    strongText[n+2],
+		r:       strongText[n+3],
+		p:       strongText[n+4],
+	}
+	if h.version != formatVersion {
+		return nil, 0, fmt.Errorf("unsupported encrypted file format version %d", h.version)
+	}
+	n += 5
+
+	// Salt and nonce are length prefixed
+	fields := []*[]byte{&h.salt, &h.nonce}
+	for _, field := range fields {
+		if len(strongText) < n+1 || len(strongText) < n+1+int(strongText[n]) {
+			return nil, 0, short
+		}
+		size := int(strongText[n])
+		*field = strongText[n+1 : n+1+size]
+		n += 1 + size
+	}
+	return h, n, nil
+}
+
+// deriveKey derives the AES-256 key from the passphrase using the KDF of the header
+func (h *header) deriveKey(passphrase string) ([]byte, error) {
+	if h.kdf != kdfScrypt {
+		return nil, fmt.Errorf("unsupported key derivation function %d", h.kdf)
+	}
+	if h.logN == 0 || h.logN > maxScryptLogN || h.r == 0 || h.p == 0 {
+		return nil, fmt.Errorf("invalid scrypt parameters")
+	}
+	return scrypt.Key([]byte(passphrase), h.salt, 1<<h.logN, int(h.r), int(h.p), derivedKeyLen)
+}
+
+// newGCM returns an AES-GCM cipher using the key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
+
+// openSealed decrypts a slice of bytes prefixed with its nonce, the format used by older versions of Wave
+func openSealed(strongText []byte, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(strongText) < nonceSize {
+		return nil, errors.New("encrypted contents are too short")
+	}
+
+	nonce, strongText := strongText[:nonceSize], strongText[nonceSize:]
+	return gcm.Open(nil, nonce, strongText, nil)
+}
+
+// isEncrypted reports whether the contents start with the header, the magic followed by the binary version byte.
+// Files encrypted by older versions of Wave aren't detected, see decryptLegacy
+func isEncrypted(contents []byte) bool {
+	return bytes.HasPrefix(contents, magic) && len(contents) > len(magic) && contents[len(magic)] < ' '
 }
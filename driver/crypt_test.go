@@ -0,0 +1,304 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	credFile := filepath.Join(t.TempDir(), "cred.yaml")
+	weakText := []byte("user: \"developer45@gmail.com\"\npass: \"password1234\"\n")
+	if err := ioutil.WriteFile(credFile, weakText, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Passphrases of any length are accepted
+	if err := Encrypt(credFile, "short"); err != nil {
+		t.Fatal(err)
+	}
+	strongText, _ := ioutil.ReadFile(credFile)
+	if !bytes.HasPrefix(strongText, magic) || bytes.Contains(strongText, []byte("developer45")) {
+		t.Errorf("Expected the file to be encrypted with the header")
+	}
+	if err := Encrypt(credFile, "short"); err != ErrAlreadyEncrypted {
+		t.Errorf("Expected %v when encrypting twice, but got %v", ErrAlreadyEncrypted, err)
+	}
+	if err := Decrypt(credFile, "wrong"); err != ErrWrongPassphrase {
+		t.Errorf("Expected %v for a wrong passphrase, but got %v", ErrWrongPassphrase, err)
+	}
+	if err := Decrypt(credFile, "short"); err != nil {
+		t.Fatal(err)
+	}
+	actual, _ := ioutil.ReadFile(credFile)
+	if !bytes.Equal(actual, weakText) {
+		t.Errorf("Expected %s, but got %s", weakText, actual)
+	}
+	if err := Decrypt(credFile, "short"); err != ErrNotEncrypted {
+		t.Errorf("Expected %v when decrypting a plain file, but got %v", ErrNotEncrypted, err)
+	}
+	if err := Encrypt(credFile, ""); err == nil {
+		t.Errorf("Expected an error for an empty passphrase")
+	}
+}
+
+// sealLegacy encrypts a slice of bytes the way older versions of Wave did, with the raw passphrase as the AES key and
+// the nonce prepended to the cipher text
+func sealLegacy(t *testing.T, weakText []byte, passphrase string) []byte {
+	gcm, err := newGCM([]byte(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	return gcm.Seal(nonce, nonce, weakText, nil)
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	credFile := filepath.Join(t.TempDir(), "cred.yaml")
+	weakText := []byte("token: \"Bearer xxxxxxxxxxxxxxxxxxxxxxxx\"\n")
+	legacy := sealLegacy(t, weakText, "mysecretpassword")
+	if err := ioutil.WriteFile(credFile, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files without the header are only decrypted by an explicit attempt with the passphrase
+	if isEncrypted(legacy) {
+		t.Errorf("Expected a file without the header not to be detected as encrypted")
+	}
+	if err := Encrypt(credFile, "mysecretpassword"); err != ErrAlreadyEncrypted {
+		t.Errorf("Expected %v when encrypting a legacy file, but got %v", ErrAlreadyEncrypted, err)
+	}
+	if err := Decrypt(credFile, "wrongpassword123"); err != ErrNotEncrypted {
+		t.Errorf("Expected %v for a wrong passphrase, but got %v", ErrNotEncrypted, err)
+	}
+	if err := Decrypt(credFile, "mysecretpassword"); err != nil {
+		t.Fatal(err)
+	}
+	actual, _ := ioutil.ReadFile(credFile)
+	if !bytes.Equal(actual, weakText) {
+		t.Errorf("Expected %s, but got %s", weakText, actual)
+	}
+
+	// Rotating a legacy file writes it with the header
+	if err := ioutil.WriteFile(credFile, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rotate(credFile, "mysecretpassword", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if encrypted, _ := IsEncrypted(credFile); !encrypted {
+		t.Errorf("Expected the rotated file to have the header")
+	}
+}
+
+func TestEncryptedHeader(t *testing.T) {
+	strongText, err := encryptBytes([]byte("user: \"admin\""), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, n, err := decodeHeader(strongText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.version != formatVersion || h.kdf != kdfScrypt || h.logN != scryptLogN || len(h.salt) != saltSize ||
+		len(h.nonce) != 12 || n != len(magic)+5+1+saltSize+1+12 {
+		t.Errorf("Unexpected header %+v of length %d", h, n)
+	}
+
+	// The header is authenticated
+	tampered := append([]byte{}, strongText...)
+	tampered[len(magic)+3] = scryptR + 1
+	if _, err := decryptBytes(tampered, "passphrase"); err != ErrWrongPassphrase {
+		t.Errorf("Expected %v for a tampered header, but got %v", ErrWrongPassphrase, err)
+	}
+	tampered[len(magic)] = formatVersion + 1
+	if _, err := decryptBytes(tampered, "passphrase"); err == nil {
+		t.Errorf("Expected an error for an unsupported version")
+	}
+
+	// Files written with version 1 of the format keep decrypting
+	v1, _ := hex.DecodeString("5741564501010f080110ded26cef28a572ee34499224f1798eac0cf82c9cb0b932446cbb7a995e00948fc0dc" +
+		"f6fb91a1de0b06b97d0e1b782b6299dc313b68e200d1f61d5a")
+	if weakText, err := decryptBytes(v1, "passphrase"); err != nil || string(weakText) != "user: \"admin\"\n" {
+		t.Errorf("Expected a version 1 file to decrypt, but got %q and %v", weakText, err)
+	}
+
+	// Files starting with WAVE as text aren't mistaken for encrypted files
+	if isEncrypted([]byte("WAVE: \"surf\"")) || !isEncrypted(strongText) {
+		t.Errorf("Expected only the header to be detected as encrypted")
+	}
+	// Neither are binary files such as Latin-1 payloads
+	if isEncrypted([]byte("name: \"Andr\xe9\"")) {
+		t.Errorf("Expected a file that isn't valid UTF-8 not to be detected as encrypted")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	credFile := filepath.Join(t.TempDir(), "cred.yaml")
+	weakText := []byte("user: \"developer45@gmail.com\"\npass: \"password1234\"\n")
+	if err := ioutil.WriteFile(credFile, weakText, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rotate(credFile, "old", "new"); err != ErrNotEncrypted {
+		t.Errorf("Expected %v when rotating a plain file, but got %v", ErrNotEncrypted, err)
+	}
+	if err := Encrypt(credFile, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(credFile, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Rotate(credFile, "wrong", "new"); err != ErrWrongPassphrase {
+		t.Errorf("Expected %v for a wrong old passphrase, but got %v", ErrWrongPassphrase, err)
+	}
+	if err := Rotate(credFile, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(credFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected the permissions to be kept, but got %v", info.Mode().Perm())
+	}
+	// No temporary files are left behind
+	entries, _ := ioutil.ReadDir(filepath.Dir(credFile))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the credentials file in the directory, but got %d files", len(entries))
+	}
+
+	if err := Decrypt(credFile, "old"); err != ErrWrongPassphrase {
+		t.Errorf("Expected the old passphrase to be rejected, but got %v", err)
+	}
+	if err := Decrypt(credFile, "new"); err != nil {
+		t.Fatal(err)
+	}
+	actual, _ := ioutil.ReadFile(credFile)
+	if !bytes.Equal(actual, weakText) {
+		t.Errorf("Expected %s, but got %s", weakText, actual)
+	}
+}
+
+func TestNewEncryptedFiles(t *testing.T) {
+	dir := t.TempDir()
+	payload := []byte(`{"name": "test", "ssn": "000-00-0000"}`)
+	files := map[string][]byte{
+		"cred.yaml":   []byte("user: \"developer45@gmail.com\"\n"),
+		"post.json":   payload,
+		"expect.json": []byte(`{"id": 1}`),
+		"reqs.yaml": []byte("signup:\n  method: post\n  base: http://localhost\n  endpoint: /users\n" +
+			"  data-file: " + filepath.Join(dir, "post.json") + "\n  expect-file: " + filepath.Join(dir, "expect.json") +
+			"\n  success-code: 201\n"),
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"post.json", "expect.json"} {
+		if err := Encrypt(filepath.Join(dir, name), "mysecretpassword"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := 0
+	key := func() (string, error) {
+		calls++
+		return "mysecretpassword", nil
+	}
+	reqs, _ := NewWithKey(filepath.Join(dir, "reqs.yaml"), filepath.Join(dir, "cred.yaml"), key)
+	if len(reqs) != 1 {
+		t.Fatalf("Expected 1 request, but got %d", len(reqs))
+	}
+	if !bytes.Equal(reqs[0].body.Bytes(), payload) {
+		t.Errorf("Expected the data file to be decrypted to %s, but got %s", payload, reqs[0].body.Bytes())
+	}
+	if ok, diff := reqs[0].compareBody(files["expect.json"]); !ok {
+		t.Errorf("Expected the expect file to be decrypted to %s, but got the diff %q", files["expect.json"], diff)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the passphrase to be asked for the 2 encrypted files, but it was asked %d times", calls)
+	}
+}
+
+func TestNewLegacyEncryptedFiles(t *testing.T) {
+	dir := t.TempDir()
+	credFile, reqFile := filepath.Join(dir, "cred.yaml"), filepath.Join(dir, "reqs.yaml")
+	legacy := sealLegacy(t, []byte("user: \"developer45@gmail.com\"\n"), "mysecretpassword")
+	if err := ioutil.WriteFile(credFile, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+	reqs := "health:\n  method: get\n  base: http://localhost\n  endpoint: /health\n  success-code: 200\n"
+	if err := ioutil.WriteFile(reqFile, []byte(reqs), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files encrypted by older versions of Wave are decrypted when running requests too
+	key := func() (string, error) { return "mysecretpassword", nil }
+	if _, chain := NewWithKey(reqFile, credFile, key); chain.User != "developer45@gmail.com" {
+		t.Errorf("Expected the legacy credentials file to be decrypted, but got %+v", chain)
+	}
+	// Binary contents the passphrase doesn't decrypt are kept as they are
+	if data := decryptFile("payload.bin", []byte("\xff\xfe"), key); string(data) != "\xff\xfe" {
+		t.Errorf("Expected a binary file to be kept as is, but got %q", data)
+	}
+}
+
+func TestEncryptBackup(t *testing.T) {
+	credFile := filepath.Join(t.TempDir(), "cred.yaml")
+	weakText := []byte("user: \"developer45@gmail.com\"\npass: \"password1234\"\n")
+	if err := ioutil.WriteFile(credFile, weakText, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptWithBackup(credFile, "mysecretpassword", true); err != nil {
+		t.Fatal(err)
+	}
+	// Secret material is only readable by its owner
+	for _, name := range []string{credFile, credFile + ".bak"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to have 0600 permissions, but got %v", name, info.Mode().Perm())
+		}
+	}
+	backup, _ := ioutil.ReadFile(credFile + ".bak")
+	if !bytes.Equal(backup, weakText) {
+		t.Errorf("Expected the backup to hold the previous version %s, but got %s", weakText, backup)
+	}
+
+	strongText, _ := ioutil.ReadFile(credFile)
+	if err := DecryptWithBackup(credFile, "mysecretpassword", true); err != nil {
+		t.Fatal(err)
+	}
+	backup, _ = ioutil.ReadFile(credFile + ".bak")
+	if !bytes.Equal(backup, strongText) {
+		t.Errorf("Expected the backup to hold the encrypted version")
+	}
+
+	// Without a backup only the file itself is written
+	if err := os.Remove(credFile + ".bak"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encrypt(credFile, "mysecretpassword"); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := ioutil.ReadDir(filepath.Dir(credFile))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the credentials file in the directory, but got %d files", len(entries))
+	}
+}
@@ -7,6 +7,7 @@ package driver
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jinzhu/copier"
 	"gopkg.in/yaml.v2"
@@ -14,11 +15,11 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // safeCounter is used to count the number of successful requests from concurrent calls to RESTful API endpoints
@@ -27,11 +28,44 @@ type safeCounter struct {
 	counter int
 }
 
-// KeyChain is used to store API credentials that can be referred to by requests in the requests YAML file
+// KeyChain is used to store API credentials that can be referred to by requests in the requests YAML file. If the
+// credentials file defines named profiles, Profiles holds them and requests select one with their Credentials field.
+// A UsersFile gives every virtual user of a splash its own user, pass and token following the Assign policy, one of
+// sequential, random or unique until exhausted. The top level TLS settings of the requests file are kept to reach the OAuth2 token
+// endpoint with
 type KeyChain struct {
-	User  string `yaml:"user"`
-	Pass  string `yaml:"pass"`
-	Token string `yaml:"token"`
+	User       string               `yaml:"user"`
+	Pass       string               `yaml:"pass"`
+	Token      string               `yaml:"token"`
+	OAuth2     *OAuth2Config        `yaml:"oauth2"`
+	APIKey     string               `yaml:"api-key"`
+	HMACKeyID  string               `yaml:"hmac-key-id"`
+	HMACSecret string               `yaml:"hmac-secret"`
+	AWS        *AWSCredentials      `yaml:"aws"`
+	JWT        *JWTConfig           `yaml:"jwt"`
+	UsersFile  string               `yaml:"users-file"`
+	Assign     string               `yaml:"assign"`
+	Profiles   map[string]*KeyChain `yaml:"-"`
+	users      []KeyChain
+	vu         int
+	tls        *TLSConfig
+}
+
+// Options holds the settings shared by every request of a run:
+// Proxy: URL of the HTTP(S) proxy to send requests through, overrides HTTP_PROXY and HTTPS_PROXY but honors NO_PROXY
+// Resolve: host:port:addr entries that pin a host and port to a specific address instead of resolving it with DNS
+// LoginPerUser: runs the authentication requests of a splash once for every virtual user instead of once in total
+// OnResponse: called with every request sent and its final response, concurrently during a splash
+// UpdateSnapshots: makes a whirlpool write the response bodies of the requests with an expect file or a snapshot to it,
+// comparing the later requests sharing the file against the first response written
+// Key: passphrase of the encrypted expect files updated with UpdateSnapshots
+type Options struct {
+	Proxy           string
+	Resolve         []string
+	LoginPerUser    bool
+	OnResponse      func(req *Request, resp *http.Response)
+	UpdateSnapshots bool
+	Key             KeySource
 }
 
 // Request is a struct that contains many fields from the net/http Request struct but also some more:
@@ -43,24 +77,67 @@ type KeyChain struct {
 // ExpectFile: filepath to JSON file containing expected response body
 // IsAuth: specifies if the method is an authentication method
 // RToken: specifies if the method requires a token
+// TokenField: dot separated path of the token in the JSON response body of an authentication request, defaults to token
+// TokenHeader: response header containing the token of an authentication request, used instead of TokenField
+// TLS: TLS settings for the request, overrides the top level TLS settings
+// Credentials: name of the credential profile used by the request, the default credentials are used if it's empty
+// Auth: auth strategy of the request, such as an API key or HMAC signature, used instead of IsAuth and RToken
+// Headers: extra headers sent with the request, ContentType and the auth strategy take precedence over them
+// ThinkTime: duration such as 1.5s that a whirlpool waits for before sending the request, like a user would
+// SchemaFile: filepath to a JSON schema, such as an OpenAPI response schema, the response body is validated against
+// Snapshot: compares the response body with a snapshot, kept in the expect file or in the snapshots directory without one
+// IgnoreFields: dot separated paths of the fields left out when comparing the response body with the expected body
 type Request struct {
-	Method       string   `yaml:"method"`
-	Base         string   `yaml:"base"`
-	Endpoint     string   `yaml:"endpoint"`
-	IdRange      []string `yaml:"id-range"`
-	SuccessCode  int      `yaml:"success-code"`
-	DataFile     string   `yaml:"data-file"`
-	ExpectFile   string   `yaml:"expect-file"`
-	ContentType  string   `yaml:"content-type"`
-	IsAuth       bool     `yaml:"is-auth"`
-	RToken       bool     `yaml:"r-token"`
+	Method       string            `yaml:"method,omitempty"`
+	Base         string            `yaml:"base,omitempty"`
+	Endpoint     string            `yaml:"endpoint,omitempty"`
+	IdRange      []string          `yaml:"id-range,omitempty"`
+	SuccessCode  int               `yaml:"success-code,omitempty"`
+	DataFile     string            `yaml:"data-file,omitempty"`
+	ExpectFile   string            `yaml:"expect-file,omitempty"`
+	ContentType  string            `yaml:"content-type,omitempty"`
+	IsAuth       bool              `yaml:"is-auth,omitempty"`
+	RToken       bool              `yaml:"r-token,omitempty"`
+	TokenField   string            `yaml:"token-field,omitempty"`
+	TokenHeader  string            `yaml:"token-header,omitempty"`
+	TLS          *TLSConfig        `yaml:"tls,omitempty"`
+	Credentials  string            `yaml:"credentials,omitempty"`
+	Auth         *AuthConfig       `yaml:"auth,omitempty"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
+	ThinkTime    string            `yaml:"think-time,omitempty"`
+	SchemaFile   string            `yaml:"schema-file,omitempty"`
+	Snapshot     bool              `yaml:"snapshot,omitempty"`
+	IgnoreFields []string          `yaml:"ignore-fields,omitempty"`
 	body         bytes.Buffer
 	expectedBody []byte
+	schema       *jsonSchema
+	// snapshotMissing is set if the snapshot file of the request wasn't written yet
+	snapshotMissing bool
 }
 
 // setToken sets the token field to the parameter token
 func (c *KeyChain) setToken(token string) {
-	c.Token = "Bearer" + token
+	c.Token = "Bearer " + token
+}
+
+// authorization returns the Authorization header value for requests requiring a token, using the OAuth2 access
+// token if OAuth2 is configured, a minted token if JWT is configured and the stored token otherwise
+func (c *KeyChain) authorization() (string, error) {
+	if c.OAuth2 != nil {
+		token, err := c.OAuth2.token("")
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	if c.JWT != nil {
+		token, err := c.JWT.token(c.vu, c.User)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return c.Token, nil
 }
 
 // String outputs Request details
@@ -69,13 +146,82 @@ func (r Request) String() string {
 		r.Method, r.Endpoint)
 }
 
+// ExpectedBody returns the contents of the expect file of the request, or its snapshot
+func (r *Request) ExpectedBody() []byte {
+	return r.expectedBody
+}
+
 // String outputs KeyChain details
 func (c *KeyChain) String() string {
 	return fmt.Sprintf("Your username: %s, Your password: %s, Your token: %s", c.User, c.Pass, c.Token)
 }
 
+// KeySource returns the passphrase of encrypted files, it is only called if an encrypted file is read
+type KeySource func() (string, error)
+
 // New creates new Request structs and returns a Keychain struct
 func New(reqFile, authFile string) ([]*Request, *KeyChain) {
+	return NewWithKey(reqFile, authFile, nil)
+}
+
+// NewWithKey creates new Request structs and returns a Keychain struct, decrypting an encrypted credentials file, data
+// files and expect files in memory with the passphrase of the KeySource
+func NewWithKey(reqFile, authFile string, key KeySource) ([]*Request, *KeyChain) {
+	// Get the credentials
+	credentials := readCredentials(authFile, key)
+	reqs, globalTLS := readRequests(reqFile, key)
+	credentials.tls = globalTLS
+
+	// Make sure the credential profiles and auth types used by the requests exist
+	for _, request := range reqs {
+		_, err := credentials.profile(request.Credentials)
+		if err != nil {
+			log.Fatalf("Check the credentials of %s %s: %v\n", request.Method, request.Endpoint, err)
+		}
+		if request.Auth != nil {
+			if _, ok := authenticators[request.Auth.Type]; !ok {
+				log.Fatalf("Check the auth of %s %s: unknown auth type %q\n", request.Method, request.Endpoint,
+					request.Auth.Type)
+			}
+		}
+		if request.ThinkTime != "" {
+			if _, err := time.ParseDuration(request.ThinkTime); err != nil {
+				log.Fatalf("Check the think-time of %s %s: %v\n", request.Method, request.Endpoint, err)
+			}
+		}
+	}
+
+	// Unpack any requests with id ranges
+	finalReqs := make([]*Request, 0)
+	for _, request := range reqs {
+		// If the request has an id range unpack the request and append it to the final slice
+		if request.IdRange != nil {
+			newReqs, err := request.unpackRequests()
+			if err != nil {
+				log.Fatalf("Improper id range bounds of %s %s: %v\n", request.Method, request.Endpoint, err)
+			}
+			finalReqs = append(finalReqs, newReqs...)
+			// Otherwise, append the original request
+		} else {
+			finalReqs = append(finalReqs, request)
+		}
+	}
+	loadSnapshots(finalReqs, key)
+	return finalReqs, credentials
+
+}
+
+// ReadRequests reads the requests YAML file along with the data, expect and schema files of the requests, without
+// unpacking their id ranges, decrypting encrypted files with the passphrase of the KeySource
+func ReadRequests(reqFile string, key KeySource) []*Request {
+	reqs, _ := readRequests(reqFile, key)
+	requireExpectFiles(reqs, false)
+	return reqs
+}
+
+// readRequests reads the requests YAML file along with the data, expect and schema files of the requests, without
+// unpacking their id ranges. It also returns the top level TLS settings
+func readRequests(reqFile string, key KeySource) ([]*Request, *TLSConfig) {
 
 	// Open yaml file
 	f, err := os.Open(reqFile)
@@ -88,50 +234,85 @@ func New(reqFile, authFile string) ([]*Request, *KeyChain) {
 			log.Fatal(err)
 		}
 	}(f)
-	// Get the credentials
-	credentials := readCredentials(authFile)
 
-	// Unmarshal yaml data into a map of Request pointers
+	// Unmarshal yaml data into a slice of Request pointers
 	data, _ := ioutil.ReadAll(f)
-	var reqs map[string]*Request
-	err = yaml.Unmarshal(data, &reqs)
+	reqs, globalTLS, err := parseRequests(data)
 	if err != nil {
-		log.Fatalf("Check the fields in your YAML requests file: %e", err)
+		log.Fatalf("Check the fields in your YAML requests file: %v", err)
 	}
-
 	// Set request bodies
 	for _, request := range reqs {
 		request.Method = strings.ToUpper(request.Method)
 		if request.DataFile != "" {
-			request.body = *readJsonFile(request.DataFile)
+			request.body = *readJsonFile(request.DataFile, key)
 		}
-		// Set the expected body of the request
+		// Set the expected body of the request, a missing expect file can be written by updating the snapshots
 		if request.ExpectFile != "" {
-			request.expectedBody = jsonToByte(request.ExpectFile)
+			if _, err := os.Stat(request.ExpectFile); errors.Is(err, os.ErrNotExist) {
+				request.snapshotMissing = true
+			} else {
+				request.expectedBody = jsonToByte(request.ExpectFile, key)
+			}
+		}
+		// Set the schema the response body is validated against
+		if request.SchemaFile != "" {
+			request.schema, err = parseSchema(jsonToByte(request.SchemaFile, key))
+			if err != nil {
+				log.Fatalf("Couldn't parse the schema file %s: %v\n", request.SchemaFile, err)
+			}
 		}
 	}
+	return reqs, globalTLS
+}
 
-	// Unpack any requests with id ranges
-	finalReqs := make([]*Request, 0)
-	for _, request := range reqs {
-		// If the request has an id range unpack the request and append it to the final slice
-		if request.IdRange != nil {
-			newReqs, err := request.unpackRequests()
+// parseRequests unmarshals the requests YAML file into Request structs in the order they were written. Top level
+// settings such as "tls" are applied to every request that doesn't set its own and returned, their names are reserved
+func parseRequests(data []byte) ([]*Request, *TLSConfig, error) {
+	var entries yaml.MapSlice
+	err := yaml.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var globalTLS *TLSConfig
+	reqs := make([]*Request, 0, len(entries))
+	for _, entry := range entries {
+		raw, err := yaml.Marshal(entry.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Top level TLS settings, the name can't be used by a request
+		if entry.Key == "tls" {
+			globalTLS = &TLSConfig{}
+			err = yaml.UnmarshalStrict(raw, globalTLS)
 			if err != nil {
-				log.Fatalf("Improper id range bounds: %e\n", err)
+				return nil, nil, fmt.Errorf("tls is reserved for the TLS settings of every request, rename a "+
+					"request named tls: %v", err)
 			}
-			finalReqs = append(finalReqs, newReqs...)
-			// Otherwise, append the original request
-		} else {
-			finalReqs = append(finalReqs, request)
+			continue
 		}
+		request := &Request{}
+		err = yaml.Unmarshal(raw, request)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v: %v", entry.Key, err)
+		}
+		reqs = append(reqs, request)
 	}
-	return finalReqs, credentials
 
+	for _, request := range reqs {
+		if request.TLS == nil {
+			request.TLS = globalTLS
+		}
+	}
+	return reqs, globalTLS, nil
 }
 
 // Splash runs the specified requests concurrently with the option to count how many requests had a status code of
-func Splash(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain) int {
+// the expected success code. Authentication requests are run before the load starts, either once or once per
+// virtual user, so that every request requiring a token uses a freshly captured one
+func Splash(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain, opts *Options) int {
+	requireExpectFiles(reqs, false)
 
 	// If a destination log file is specified set it as the output otherwise stick with stdout
 	var out *os.File
@@ -168,14 +349,41 @@ func Splash(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain
 
 	start := time.Now()
 	successes := safeCounter{}
-	client := &http.Client{
-		Timeout: 15 * time.Second,
+	clients := newClients(reqs, opts)
+	startOAuth2(chain, opts)
+	authReqs, loadReqs := splitAuthRequests(reqs)
+	total := len(loadReqs) * its
+
+	// Run the authentication requests before the load starts, every virtual user gets its own KeyChain if
+	// logins are done per user or credentials are checked out of a users file or minted per user
+	perUser := (opts != nil && opts.LoginPerUser) || chain.perUser()
+	chains := make([]*KeyChain, its)
+	for i := range chains {
+		if i > 0 && !perUser {
+			chains[i] = chains[0]
+			continue
+		}
+		chains[i] = chain
+		if perUser {
+			userChain, err := chain.forUser(i, its)
+			if err != nil {
+				log.Fatalf("Couldn't check out credentials: %v\n", err)
+			}
+			chains[i] = userChain
+		}
+		for _, req := range authReqs {
+			total++
+			if req.login(clients[req.TLS], chains[i], out, opts) {
+				successes.counter++
+			}
+		}
 	}
 	var wg sync.WaitGroup
 
 	// Run the requests for its sets and
 	for i := 0; i < its; i++ {
-		for _, req := range reqs {
+		userChain := chains[i]
+		for _, req := range loadReqs {
 			wg.Add(1)
 			req := req
 			// Create goroutines for each request
@@ -185,46 +393,18 @@ func Splash(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain
 					successes.Lock()
 					defer successes.Unlock()
 				}
-				r, err := req.prepareRequest(chain)
-				if err != nil {
-					log.Fatalf("Couldn't construct %s\n", req)
-				}
-				// Set other parameters besides the Request body
-				reqStart := time.Now()
-				resp, err := client.Do(r)
-				if err != nil {
-					log.Fatalf("%s timed out\n", req)
-				}
-				// Log to output file or stdout
-				code := resp.StatusCode
-				message := fmt.Sprintf("%s %d %d, %s\n", req, code, resp.ContentLength, time.Since(reqStart))
-				if dest != "" {
-					_, err := out.WriteString(message)
-					if err != nil {
-						log.Fatal(err)
-					}
-				} else {
-					fmt.Print(message)
-				}
+				resp, body := req.send(clients[req.TLS], userChain, out, opts)
 
 				// If the status code is the same as the expected and verbose flag is on increment successes and output the json
-				if code == req.SuccessCode && verbose {
+				if resp.StatusCode == req.SuccessCode && verbose {
 					var formattedJSON bytes.Buffer
-					body, _ := ioutil.ReadAll(resp.Body)
 					// If the request has an expected file, check if the response json body matches with the expected body
-					err = json.Indent(&formattedJSON, body, "", "    ")
+					err := json.Indent(&formattedJSON, body, "", "    ")
 					if err != nil {
 						log.Fatalf("Error printing response body for %s\n", req)
 					}
 					log.Printf("Response body: %s\n", formattedJSON.String())
-					if req.ExpectFile != "" {
-						match := jsonEqual(req.expectedBody, body)
-						if match {
-							successes.counter++
-						} else {
-							log.Println("Response JSON body does NOT match expected JSON body")
-						}
-					} else {
+					if req.matchesBody(body) {
 						successes.counter++
 					}
 				}
@@ -233,8 +413,9 @@ func Splash(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain
 	}
 	wg.Wait()
 	log.Printf("Total execution time: %s\n", time.Since(start))
+	logOAuth2Stats(chain)
 	if verbose {
-		log.Printf("%d out of %d successful requests\n", successes.counter, len(reqs)*its)
+		log.Printf("%d out of %d successful requests\n", successes.counter, total)
 		return successes.counter
 	}
 
@@ -244,7 +425,8 @@ func Splash(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain
 }
 
 // Whirlpool runs the specified requests cyclically for a specified number of iterations
-func Whirlpool(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain) int {
+func Whirlpool(its int, reqs []*Request, verbose bool, dest string, chain *KeyChain, opts *Options) int {
+	requireExpectFiles(reqs, opts != nil && opts.UpdateSnapshots)
 
 	// If a destination log file is specified set it as the output otherwise stick with stdout
 	var out *os.File
@@ -277,61 +459,52 @@ func Whirlpool(its int, reqs []*Request, verbose bool, dest string, chain *KeyCh
 	}
 	log.Println(startMessage)
 	absStart := time.Now()
-	client := &http.Client{
-		Timeout: 15 * time.Second,
-	}
+	clients := newClients(reqs, opts)
+	startOAuth2(chain, opts)
 	successes := 0
+	updated := make(map[string][]byte)
+
+	// A sequential run is a single virtual user
+	if chain.perUser() {
+		userChain, err := chain.forUser(0, 1)
+		if err != nil {
+			log.Fatalf("Couldn't check out credentials: %v\n", err)
+		}
+		chain = userChain
+	}
 
 	for i := 0; i < its; i++ {
 		for _, req := range reqs {
-			// Get the prepared request
-			r, err := req.prepareRequest(chain)
-			if err != nil {
-				log.Fatalf("Couldn't construct %s\n", req)
-			}
-
-			// Get start time and run the request
-			reqStart := time.Now()
-			resp, e := client.Do(r)
-			if e != nil {
-				log.Fatalf("%s timed out\n", req)
+			// Pause like a user would before sending the request
+			if think, err := time.ParseDuration(req.ThinkTime); err == nil && think > 0 {
+				time.Sleep(think)
 			}
-			// Log using common log format
+			resp, body := req.send(clients[req.TLS], chain, out, opts)
 			code := resp.StatusCode
-			message := fmt.Sprintf("%s %d %d, %s\n", req, code, resp.ContentLength, time.Since(reqStart))
-			if dest != "" {
-				_, err := out.WriteString(message)
-				if err != nil {
-					log.Fatal(err)
-				}
-			} else {
-				fmt.Print(message)
-			}
 
-			// Get the API token from the POST response body
-			if req.Method == "POST" && req.IsAuth {
-				var tokenMap map[string]string
-				body, _ := ioutil.ReadAll(resp.Body)
-				err := json.Unmarshal(body, &tokenMap)
+			// Get the API token from the response of authentication requests
+			if req.IsAuth {
+				err := req.captureToken(resp, body, chain)
 				if err != nil {
-					log.Fatal(err)
+					log.Printf("Couldn't capture the token from %s: %v\n", req, err)
 				}
-				chain.setToken(tokenMap["token"])
 			}
 			var formattedJSON bytes.Buffer
-			body, _ := ioutil.ReadAll(resp.Body)
-			// If the status codes and bodies match increment successes
-			if code == req.SuccessCode {
-				if req.ExpectFile != "" {
-					if jsonEqual(body, req.expectedBody) {
-						successes++
-					} else {
-						log.Println("Response JSON body does NOT match expected JSON body")
-					}
+			// Write the first response body of every file to the snapshot, later requests sharing the file are
+			// compared against the snapshot just written
+			if opts != nil && opts.UpdateSnapshots && req.ExpectFile != "" && code == req.SuccessCode {
+				if written, ok := updated[req.ExpectFile]; ok {
+					req.expectedBody, req.snapshotMissing = written, false
+				} else if err := req.updateSnapshot(body, opts.Key); err != nil {
+					log.Printf("Couldn't update the snapshot %s: %v\n", req.ExpectFile, err)
 				} else {
-					successes++
+					updated[req.ExpectFile] = req.expectedBody
+					log.Printf("Updated the snapshot %s\n", req.ExpectFile)
 				}
-
+			}
+			// If the status codes and bodies match increment successes
+			if code == req.SuccessCode && req.matchesBody(body) {
+				successes++
 			}
 			// If verbose is enabled output json
 			if verbose {
@@ -345,6 +518,7 @@ func Whirlpool(its int, reqs []*Request, verbose bool, dest string, chain *KeyCh
 	}
 
 	log.Printf("Total execution time: %s\n", time.Since(absStart))
+	logOAuth2Stats(chain)
 	if verbose {
 		log.Printf("%d out of %d successful requests\n", successes, len(reqs)*its)
 	}
@@ -354,42 +528,120 @@ func Whirlpool(its int, reqs []*Request, verbose bool, dest string, chain *KeyCh
 
 // prepareRequest returns http.Request structs with authentication or authorization if needed
 func (r *Request) prepareRequest(key *KeyChain) (*http.Request, error) {
-	req, err := http.NewRequest(r.Method, r.Base+r.Endpoint, &r.body)
+	req, err := http.NewRequest(r.Method, r.Base+r.Endpoint, bytes.NewReader(r.body.Bytes()))
+	if err != nil {
+		return &http.Request{}, err
+	}
+	key, err = key.profile(r.Credentials)
 	if err != nil {
 		return &http.Request{}, err
 	}
 	// Set headers appropriately
-	req.Header.Set("Content-Type", r.ContentType)
-	if r.IsAuth {
-		req.SetBasicAuth(key.User, key.Pass)
-	} else if r.RToken {
-		req.Header.Set("Authorization", key.Token)
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+
+	// Authenticate last so that signatures cover the final body and headers
+	err = r.authenticate(req, key)
+	if err != nil {
+		return &http.Request{}, err
 	}
 
 	return req, nil
 
 }
 
+// send prepares and runs the request, logs it using common log format to the output file or stdout and returns the
+// response along with its body. Requests using an OAuth2 access token are retried once with a refreshed token if
+// they are unauthorized. The final response of the request is passed to the OnResponse callback of the options
+func (r *Request) send(client *http.Client, chain *KeyChain, out *os.File, opts *Options) (*http.Response, []byte) {
+	for attempt := 0; ; attempt++ {
+		req, err := r.prepareRequest(chain)
+		if err != nil {
+			log.Fatalf("Couldn't construct %s: %v\n", r, err)
+		}
+
+		// Get start time and run the request
+		reqStart := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Fatalf("%s timed out\n", r)
+		}
+		elapsed := time.Since(reqStart)
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		// Log to output file or stdout
+		message := fmt.Sprintf("%s %d %d, %s\n", r, resp.StatusCode, resp.ContentLength, elapsed)
+		if out != nil {
+			_, err := out.WriteString(message)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			fmt.Print(message)
+		}
+
+		// Refresh the access token if it was rejected and retry, only the final response is reported
+		keys, _ := chain.profile(r.Credentials)
+		if resp.StatusCode == http.StatusUnauthorized && r.usesToken() && keys.OAuth2 != nil && attempt == 0 {
+			rejected := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			_, err := keys.OAuth2.token(rejected)
+			if err == nil {
+				continue
+			}
+			log.Printf("Couldn't refresh the OAuth2 access token: %v\n", err)
+		}
+		if opts != nil && opts.OnResponse != nil {
+			opts.OnResponse(r, resp)
+		}
+		return resp, body
+	}
+}
+
+// matchesBody reports whether the response body matches the expect file and the schema of the request, logging why
+// it doesn't
+func (r *Request) matchesBody(body []byte) bool {
+	matches := true
+	if r.snapshotMissing {
+		log.Printf("Snapshot %s doesn't exist yet, run whirl with --update-snapshots to write it\n", r.ExpectFile)
+		matches = false
+	} else if r.ExpectFile != "" {
+		if ok, diff := r.compareBody(body); !ok {
+			r.logDiff(diff)
+			matches = false
+		}
+	}
+	if r.schema != nil {
+		if errs := r.schema.validateJSON(body); len(errs) > 0 {
+			log.Printf("Response JSON body does NOT match the schema %s:\n\t%s\n", r.SchemaFile,
+				strings.Join(errs, "\n\t"))
+			matches = false
+		}
+	}
+	return matches
+}
+
 // unpackRequests returns a slice of *Request structs for a given Request struct with an IdRange
 func (r *Request) unpackRequests() ([]*Request, error) {
 	finalRequests := make([]*Request, 0)
 
-	// If the ids aren't numbers or IdRange is greater than 2, iterate over them normally
-	upper, err := strconv.Atoi(r.IdRange[1])
-	lower, e := strconv.Atoi(r.IdRange[0])
-	if err != nil || e != nil || len(r.IdRange) > 2 {
+	// If the ids aren't numbers or IdRange isn't a pair of bounds, iterate over them normally
+	var upper, lower int
+	var err, e error
+	if len(r.IdRange) == 2 {
+		upper, err = strconv.Atoi(r.IdRange[1])
+		lower, e = strconv.Atoi(r.IdRange[0])
+	}
+	if len(r.IdRange) != 2 || err != nil || e != nil {
 		for _, id := range r.IdRange {
-			// Create the new endpoint
-			newEndpoint := strings.ReplaceAll(r.Endpoint, "{id}", id)
-			newReq := &Request{}
-			err := copier.Copy(&newReq, r)
+			newReq, err := r.withID(id)
 			if err != nil {
 				return nil, err
 			}
-			// Set the endpoint and clear the other fields
-			newReq.Endpoint = newEndpoint
-			newReq.IdRange = nil
-
 			finalRequests = append(finalRequests, newReq)
 		}
 
@@ -397,51 +649,46 @@ func (r *Request) unpackRequests() ([]*Request, error) {
 	}
 	// Improper bounds
 	if !(upper > lower) {
-		var err error
-		return nil, err
+		return nil, fmt.Errorf("the upper bound %d should be greater than the lower bound %d", upper, lower)
 	}
 	for i := lower; i <= upper; i++ {
-		// Create the new endpoint
-		newEndpoint := strings.ReplaceAll(r.Endpoint, "{id}", strconv.Itoa(i))
-		newReq := &Request{}
-		err := copier.Copy(&newReq, r)
+		newReq, err := r.withID(strconv.Itoa(i))
 		if err != nil {
 			return nil, err
 		}
-		// Set the endpoint and clear the other fields
-		newReq.Endpoint = newEndpoint
-		newReq.IdRange = nil
-
 		finalRequests = append(finalRequests, newReq)
 	}
 
 	return finalRequests, nil
 }
 
-// jsonEqual compares two slices of JSONified bytes, returns true if they match, otherwise false
-func jsonEqual(a, b []byte) bool {
-	var j, j2 interface{}
-	if err := json.Unmarshal(a, &j); err != nil {
-		fmt.Println("here")
-		fmt.Println(j)
-		return false
-	}
-	if err := json.Unmarshal(b, &j2); err != nil {
-		fmt.Println("there")
-		return false
+// withID returns a copy of the request for a single id of its IdRange
+func (r *Request) withID(id string) (*Request, error) {
+	newReq := &Request{}
+	err := copier.Copy(&newReq, r)
+	if err != nil {
+		return nil, err
 	}
-	return reflect.DeepEqual(j2, j)
-
+	// Set the endpoint and clear the other fields
+	newReq.Endpoint = strings.ReplaceAll(r.Endpoint, "{id}", id)
+	newReq.IdRange = nil
+
+	// Copy the unexported fields skipped by copier
+	newReq.body = *bytes.NewBuffer(r.body.Bytes())
+	newReq.expectedBody = r.expectedBody
+	newReq.snapshotMissing = r.snapshotMissing
+	newReq.schema = r.schema
+	return newReq, nil
 }
 
 // readJsonFile reads in JSON files for Create, Update, and Delete requests
-func readJsonFile(filepath string) *bytes.Buffer {
-	byteValue := jsonToByte(filepath)
+func readJsonFile(filepath string, key KeySource) *bytes.Buffer {
+	byteValue := jsonToByte(filepath, key)
 	return bytes.NewBuffer(byteValue)
 }
 
-// jsonToByte converts a JSON file to a slice of bytes
-func jsonToByte(filepath string) []byte {
+// jsonToByte converts a JSON file to a slice of bytes, decrypting it in memory if it is encrypted
+func jsonToByte(filepath string, key KeySource) []byte {
 	jsonFile, err := os.Open(filepath)
 	if err != nil {
 		log.Fatalf("Couldn't open json file at %s\n", filepath)
@@ -454,11 +701,12 @@ func jsonToByte(filepath string) []byte {
 	}(jsonFile)
 
 	byteValue, _ := ioutil.ReadAll(jsonFile)
-	return byteValue
+	return decryptFile(filepath, byteValue, key)
 }
 
-// readCredentials returns a KeyChain struct from a yaml file
-func readCredentials(filepath string) *KeyChain {
+// readCredentials returns a KeyChain struct from a yaml file, an encrypted file is decrypted in memory so that the
+// plaintext credentials are never written to disk
+func readCredentials(filepath string, key KeySource) *KeyChain {
 
 	yamlFile, err := os.Open(filepath)
 	if err != nil {
@@ -472,12 +720,45 @@ func readCredentials(filepath string) *KeyChain {
 	}(yamlFile)
 
 	data, _ := ioutil.ReadAll(yamlFile)
-	keys := KeyChain{}
-	err = yaml.Unmarshal(data, &keys)
+	keys, err := parseCredentials(decryptFile(filepath, data, key), key)
 
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
 
-	return &keys
+	return keys
+}
+
+// decryptFile returns the contents of the file at the filepath, decrypting them with the passphrase of the KeySource if
+// the file was encrypted with the protect command. Files encrypted by older versions of Wave have no header, so
+// contents that aren't valid UTF-8 are tried as one and kept as they are if the passphrase doesn't decrypt them
+func decryptFile(filepath string, data []byte, key KeySource) []byte {
+	encrypted := isEncrypted(data)
+	if !encrypted && utf8.Valid(data) {
+		return data
+	}
+	if key == nil {
+		if !encrypted {
+			log.Printf("WARNING: %s isn't valid UTF-8, if it was encrypted by an older version of Wave run wave "+
+				"protect rotate to upgrade it\n", filepath)
+			return data
+		}
+		log.Fatalf("File %s is encrypted, a passphrase is needed to decrypt it", filepath)
+	}
+	passphrase, err := key()
+	if err != nil {
+		log.Fatalf("Couldn't get the passphrase of %s: %v", filepath, err)
+	}
+	weakText, err := decryptFileBytes(data, passphrase)
+	if err == ErrNotEncrypted {
+		return data
+	}
+	if err != nil {
+		log.Fatalf("Couldn't decrypt file %s: %v", filepath, err)
+	}
+	if !encrypted {
+		log.Printf("WARNING: %s was encrypted by an older version of Wave, run wave protect rotate to upgrade it\n",
+			filepath)
+	}
+	return weakText
 }
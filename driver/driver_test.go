@@ -5,28 +5,37 @@ Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
 package driver
 
 import (
+	"fmt"
+	"github.com/fercevik729/Wave/echoserver"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestWhirlpool(t *testing.T) {
+	server := echoserver.New(nil)
+	defer server.Close()
+
 	reqs := make([]*Request, 0)
 	reqs = append(reqs, &Request{
 		Method:      "GET",
-		Base:        "https://api.sampleapis.com",
-		Endpoint:    "/coffee/hot",
+		Base:        server.URL,
+		Endpoint:    "/echo",
 		SuccessCode: 200,
 	}, &Request{
 		Method:      "POST",
-		Base:        "https://postman-echo.com",
-		Endpoint:    "/post",
+		Base:        server.URL,
+		Endpoint:    "/echo",
 		SuccessCode: 200,
-		DataFile:    "./data/post.json",
 		ContentType: "application/json",
 		IsAuth:      false,
 		RToken:      false,
 	})
-	actual := Whirlpool(10, reqs, false, "", &KeyChain{})
+	reqs[1].body.WriteString(`{"foo1": "bar1", "foo2": "bar2"}`)
+	actual := Whirlpool(10, reqs, false, "", &KeyChain{}, nil)
 	expected := 20
 	if actual != expected {
 		t.Errorf("Expected %d successes, but got %d successes\n", expected, actual)
@@ -34,28 +43,32 @@ func TestWhirlpool(t *testing.T) {
 }
 
 func TestSplash(t *testing.T) {
+	server := echoserver.New(nil)
+	defer server.Close()
+
 	reqs := make([]*Request, 0)
 	reqs = append(reqs, &Request{
 		Method:      "GET",
-		Base:        "https://api.sampleapis.com",
-		Endpoint:    "/coffee/hot",
+		Base:        server.URL,
+		Endpoint:    "/auth/protected",
 		SuccessCode: 200,
+		RToken:      true,
 	},
 		&Request{
 			Method:      "POST",
-			Base:        "https://postman-echo.com",
-			Endpoint:    "/post",
+			Base:        server.URL,
+			Endpoint:    "/auth/token",
 			SuccessCode: 200,
-			DataFile:    "./data/post.json",
 			ContentType: "application/json",
 			IsAuth:      true,
 			RToken:      false,
 		})
 
-	actual := Splash(10, reqs, true, "", &KeyChain{})
-	expected := 20
+	// The authentication request runs once before the 10 sets
+	actual := Splash(10, reqs, true, "", &KeyChain{}, nil)
+	expected := 11
 	if actual != expected {
-		t.Errorf("Expected %d successes, but got %d successes\n", actual, expected)
+		t.Errorf("Expected %d successes, but got %d successes\n", expected, actual)
 	}
 }
 
@@ -98,7 +111,103 @@ func TestNew(t *testing.T) {
 	if !reflect.DeepEqual(actualReqs, expectedReqs) {
 		t.Errorf("Requests: expected %v, but got %v", expectedReqs, actualReqs)
 	}
-	if *actChain != *expectedChain {
+	if !reflect.DeepEqual(actChain, expectedChain) {
 		t.Errorf("Keychain: expected %v, but got %v", expectedChain, actChain)
 	}
 }
+
+func TestWhirlpoolThinkTime(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		if r.Header.Get("X-Session") != "abc" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	reqs := []*Request{{
+		Method:      "GET",
+		Base:        server.URL,
+		Endpoint:    "/first",
+		SuccessCode: 200,
+		Headers:     map[string]string{"X-Session": "abc"},
+	}, {
+		Method:      "GET",
+		Base:        server.URL,
+		Endpoint:    "/second",
+		SuccessCode: 200,
+		Headers:     map[string]string{"X-Session": "abc"},
+		ThinkTime:   "200ms",
+	}}
+	actual := Whirlpool(1, reqs, false, "", &KeyChain{}, nil)
+	if actual != 2 {
+		t.Errorf("Expected 2 successes, but got %d successes", actual)
+	}
+	if len(times) != 2 || times[1].Sub(times[0]) < 200*time.Millisecond {
+		t.Errorf("Expected the second request to be sent after its think time")
+	}
+}
+
+func TestSplashOnResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	reqs := []*Request{
+		{Method: "GET", Base: server.URL, Endpoint: "/found", SuccessCode: 200},
+		{Method: "GET", Base: server.URL, Endpoint: "/missing", SuccessCode: 200},
+	}
+	var mu sync.Mutex
+	codes := make(map[string]int)
+	opts := &Options{OnResponse: func(req *Request, resp *http.Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		codes[fmt.Sprintf("%s %d", req.Endpoint, resp.StatusCode)]++
+	}}
+	Splash(3, reqs, false, "", &KeyChain{}, opts)
+	expected := map[string]int{"/found 200": 3, "/missing 404": 3}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Errorf("Expected the responses %v, but got %v", expected, codes)
+	}
+}
+
+func TestUnpackRequests(t *testing.T) {
+	req := &Request{Method: "POST", Endpoint: "/users/{id}", IdRange: []string{"7"}, expectedBody: []byte(`{}`)}
+	req.body.WriteString(`{"name": "wave"}`)
+
+	// A single id
+	reqs, err := req.unpackRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 1 || reqs[0].Endpoint != "/users/7" {
+		t.Fatalf("Expected a single request for /users/7, but got %v", reqs)
+	}
+	// The body and expected body are kept
+	if reqs[0].body.String() != `{"name": "wave"}` || string(reqs[0].expectedBody) != `{}` {
+		t.Errorf("Expected the body to be copied, but got %q", reqs[0].body.String())
+	}
+
+	req.IdRange = []string{"3", "5"}
+	reqs, err = req.unpackRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 3 || reqs[2].Endpoint != "/users/5" {
+		t.Errorf("Expected requests for ids 3 to 5, but got %v", reqs)
+	}
+
+	req.IdRange = []string{"5", "3"}
+	if _, err := req.unpackRequests(); err == nil {
+		t.Errorf("Expected an error for improper bounds")
+	}
+}
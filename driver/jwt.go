@@ -0,0 +1,238 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTConfig holds the settings used to mint JSON Web Tokens for requests requiring a token:
+// Algorithm: signing algorithm, one of HS256, RS256 or ES256
+// Secret: shared secret of HS256 tokens
+// KeyFile: filepath to the PEM private key of RS256 and ES256 tokens
+// Issuer, Audience, Subject: iss, aud and sub claims
+// Claims: custom claims
+// Expiry: lifetime of the tokens such as 15m, defaults to 15 minutes
+// String claims may use the {vu} placeholder for the number of the virtual user and {user} for its username
+type JWTConfig struct {
+	Algorithm string                 `yaml:"algorithm"`
+	Secret    string                 `yaml:"secret"`
+	KeyFile   string                 `yaml:"key-file"`
+	Issuer    string                 `yaml:"issuer"`
+	Audience  string                 `yaml:"audience"`
+	Subject   string                 `yaml:"subject"`
+	Claims    map[string]interface{} `yaml:"claims"`
+	Expiry    string                 `yaml:"expiry"`
+
+	mu     sync.Mutex
+	key    crypto.Signer
+	tokens map[int]mintedToken
+}
+
+// mintedToken is a token minted for a virtual user along with its expiry
+type mintedToken struct {
+	token   string
+	expires time.Time
+}
+
+// token returns the token of the virtual user, minting a new one if there is none or it is close to expiring
+func (j *JWTConfig) token(vu int, user string) (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	lifetime := 15 * time.Minute
+	if j.Expiry != "" {
+		d, err := time.ParseDuration(j.Expiry)
+		if err != nil {
+			return "", fmt.Errorf("invalid jwt expiry %q: %v", j.Expiry, err)
+		}
+		lifetime = d
+	}
+	// Tokens are minted again once less than a tenth of their lifetime or the expiry delta is left
+	margin := lifetime / 10
+	if margin < expiryDelta {
+		margin = expiryDelta
+	}
+	if minted, ok := j.tokens[vu]; ok && time.Now().Add(margin).Before(minted.expires) {
+		return minted.token, nil
+	}
+
+	now := time.Now()
+	token, err := j.mint(vu, user, now, lifetime)
+	if err != nil {
+		return "", err
+	}
+	if j.tokens == nil {
+		j.tokens = make(map[int]mintedToken)
+	}
+	j.tokens[vu] = mintedToken{token: token, expires: now.Add(lifetime)}
+	return token, nil
+}
+
+// mint creates and signs a token for the virtual user, the caller must hold the lock
+func (j *JWTConfig) mint(vu int, user string, now time.Time, lifetime time.Duration) (string, error) {
+	template := strings.NewReplacer("{vu}", strconv.Itoa(vu), "{user}", user)
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	claims := map[string]interface{}{
+		"iat": now.Unix(),
+		"exp": now.Add(lifetime).Unix(),
+		"jti": hex.EncodeToString(id),
+	}
+	for name, value := range j.Claims {
+		claims[name] = templateClaim(jsonValue(value), template)
+	}
+	if j.Issuer != "" {
+		claims["iss"] = template.Replace(j.Issuer)
+	}
+	if j.Audience != "" {
+		claims["aud"] = template.Replace(j.Audience)
+	}
+	if j.Subject != "" {
+		claims["sub"] = template.Replace(j.Subject)
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": j.Algorithm, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := j.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// sign signs the input with the algorithm of the JWTConfig, the caller must hold the lock
+func (j *JWTConfig) sign(input []byte) ([]byte, error) {
+	if j.Algorithm == "HS256" {
+		if j.Secret == "" {
+			return nil, fmt.Errorf("HS256 tokens require a secret")
+		}
+		mac := hmac.New(sha256.New, []byte(j.Secret))
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+
+	if j.key == nil {
+		key, err := readSigningKey(j.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		j.key = key
+	}
+	digest := sha256.Sum256(input)
+	switch j.Algorithm {
+	case "RS256":
+		key, ok := j.key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("RS256 tokens require an RSA key")
+		}
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case "ES256":
+		key, ok := j.key.(*ecdsa.PrivateKey)
+		if !ok || key.Curve.Params().BitSize != 256 {
+			return nil, fmt.Errorf("ES256 tokens require a P-256 ECDSA key")
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// ES256 signatures are the 32 byte big endian r and s values
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q, use HS256, RS256 or ES256", j.Algorithm)
+	}
+}
+
+// readSigningKey reads a PKCS #8, PKCS #1 or SEC 1 PEM private key
+func readSigningKey(filepath string) (crypto.Signer, error) {
+	if filepath == "" {
+		return nil, fmt.Errorf("RS256 and ES256 tokens require a key-file")
+	}
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found in %s", filepath)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key in %s", filepath)
+}
+
+// templateClaim replaces the placeholders of the string values of a claim
+func templateClaim(value interface{}, template *strings.Replacer) interface{} {
+	switch v := value.(type) {
+	case string:
+		return template.Replace(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = templateClaim(v[i], template)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = templateClaim(v[k], template)
+		}
+	}
+	return value
+}
+
+// jsonValue converts the nested maps produced by YAML unmarshalling into maps that can be marshalled to JSON
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = jsonValue(val)
+		}
+		return s
+	}
+	return value
+}
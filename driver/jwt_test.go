@@ -0,0 +1,132 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// decodeJWT splits a token into its claims, signing input and signature
+func decodeJWT(t *testing.T, token string) (map[string]interface{}, []byte, []byte) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a token with 3 parts, but got %s", token)
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims, []byte(parts[0] + "." + parts[1]), signature
+}
+
+// writeKey writes a PKCS #8 PEM private key and returns its path
+func writeKey(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile
+}
+
+func TestJWTHS256(t *testing.T) {
+	chain := &KeyChain{User: "ann", JWT: &JWTConfig{
+		Algorithm: "HS256",
+		Secret:    "s3cret",
+		Issuer:    "wave",
+		Subject:   "user-{vu}",
+		Claims:    map[string]interface{}{"email": "{user}@example.com", "roles": []interface{}{"reader"}},
+	}}
+
+	// Every virtual user gets its own templated token
+	userChain, err := chain.forUser(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := userChain.authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, input, signature := decodeJWT(t, strings.TrimPrefix(auth, "Bearer "))
+	if claims["sub"] != "user-2" || claims["email"] != "ann@example.com" || claims["iss"] != "wave" {
+		t.Errorf("Expected templated claims, but got %v", claims)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(input)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		t.Errorf("Expected a valid HS256 signature")
+	}
+
+	// Tokens are cached until they are close to expiring
+	again, _ := userChain.authorization()
+	if again != auth {
+		t.Errorf("Expected the cached token to be reused")
+	}
+	j := &JWTConfig{Algorithm: "HS256", Secret: "s3cret", Expiry: "5s"}
+	first, _ := j.token(1, "")
+	second, _ := j.token(1, "")
+	if first == second {
+		t.Errorf("Expected tokens close to expiring to be minted again")
+	}
+}
+
+func TestJWTAsymmetric(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &JWTConfig{Algorithm: "RS256", KeyFile: writeKey(t, rsaKey), Audience: "api"}
+	token, err := j.token(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, input, signature := decodeJWT(t, token)
+	digest := sha256.Sum256(input)
+	if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("Expected a valid RS256 signature, but got %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j = &JWTConfig{Algorithm: "ES256", KeyFile: writeKey(t, ecKey)}
+	token, err = j.token(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, input, signature = decodeJWT(t, token)
+	digest = sha256.Sum256(input)
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if len(signature) != 64 || !ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s) {
+		t.Errorf("Expected a valid ES256 signature")
+	}
+
+	// The key must match the algorithm
+	j = &JWTConfig{Algorithm: "RS256", KeyFile: writeKey(t, ecKey)}
+	if _, err := j.token(1, ""); err == nil {
+		t.Errorf("Expected an error for an ECDSA key with RS256")
+	}
+}
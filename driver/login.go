@@ -0,0 +1,89 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// splitAuthRequests separates the authentication requests from the rest of the requests
+func splitAuthRequests(reqs []*Request) ([]*Request, []*Request) {
+	authReqs, loadReqs := make([]*Request, 0), make([]*Request, 0)
+	for _, req := range reqs {
+		if req.IsAuth {
+			authReqs = append(authReqs, req)
+		} else {
+			loadReqs = append(loadReqs, req)
+		}
+	}
+	return authReqs, loadReqs
+}
+
+// login runs an authentication request and stores the captured token in the KeyChain, it returns true if the
+// request succeeded and a token was captured
+func (r *Request) login(client *http.Client, chain *KeyChain, out *os.File, opts *Options) bool {
+	resp, body := r.send(client, chain, out, opts)
+	if resp.StatusCode != r.SuccessCode {
+		log.Printf("Authentication request %s failed with status code %d\n", r, resp.StatusCode)
+		return false
+	}
+	err := r.captureToken(resp, body, chain)
+	if err != nil {
+		log.Printf("Couldn't capture the token from %s: %v\n", r, err)
+		return false
+	}
+	return true
+}
+
+// captureToken reads the token from the response header or JSON body of an authentication request and stores it in
+// the credential profile of the request
+func (r *Request) captureToken(resp *http.Response, body []byte, chain *KeyChain) error {
+	chain, err := chain.profile(r.Credentials)
+	if err != nil {
+		return err
+	}
+	if r.TokenHeader != "" {
+		token := resp.Header.Get(r.TokenHeader)
+		if token == "" {
+			return fmt.Errorf("response header %s is empty", r.TokenHeader)
+		}
+		chain.setToken(strings.TrimPrefix(token, "Bearer "))
+		return nil
+	}
+
+	field := r.TokenField
+	if field == "" {
+		field = "token"
+	}
+	var data interface{}
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return err
+	}
+	// Walk down the dot separated path
+	for _, key := range strings.Split(field, ".") {
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("token field %s not found in response body", field)
+		}
+		if data, ok = object[key]; !ok {
+			return fmt.Errorf("token field %s not found in response body", field)
+		}
+	}
+	switch token := data.(type) {
+	case string:
+		chain.setToken(token)
+	case float64:
+		chain.setToken(fmt.Sprint(token))
+	default:
+		return fmt.Errorf("token field %s is not a string", field)
+	}
+	return nil
+}
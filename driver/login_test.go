@@ -0,0 +1,92 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newLoginServer returns a test server issuing the token abc on /login and requiring it on /secure
+func newLoginServer(logins *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(logins, 1)
+		w.Header().Set("X-Auth-Token", "abc")
+		_, _ = w.Write([]byte(`{"data": {"access_token": "abc"}}`))
+	})
+	mux.HandleFunc("/secure", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSplashLogin(t *testing.T) {
+	var logins int32
+	server := newLoginServer(&logins)
+	defer server.Close()
+
+	reqs := []*Request{{
+		Method:      "POST",
+		Base:        server.URL,
+		Endpoint:    "/login",
+		SuccessCode: 200,
+		IsAuth:      true,
+		TokenField:  "data.access_token",
+	}, {
+		Method:      "GET",
+		Base:        server.URL,
+		Endpoint:    "/secure",
+		SuccessCode: 200,
+		RToken:      true,
+	}}
+
+	// The authentication request is only run once before the load
+	chain := &KeyChain{Token: "Bearer stale"}
+	actual := Splash(5, reqs, true, "", chain, nil)
+	if actual != 6 || logins != 1 {
+		t.Errorf("Expected 6 successes with 1 login, but got %d successes with %d logins", actual, logins)
+	}
+	if chain.Token != "Bearer abc" {
+		t.Errorf("Expected the captured token to be stored, but got %s", chain.Token)
+	}
+
+	// Once per virtual user
+	logins = 0
+	actual = Splash(5, reqs, true, "", &KeyChain{}, &Options{LoginPerUser: true})
+	if actual != 10 || logins != 5 {
+		t.Errorf("Expected 10 successes with 5 logins, but got %d successes with %d logins", actual, logins)
+	}
+}
+
+func TestWhirlpoolTokenHeader(t *testing.T) {
+	var logins int32
+	server := newLoginServer(&logins)
+	defer server.Close()
+
+	reqs := []*Request{{
+		Method:      "POST",
+		Base:        server.URL,
+		Endpoint:    "/login",
+		SuccessCode: 200,
+		IsAuth:      true,
+		TokenHeader: "X-Auth-Token",
+	}, {
+		Method:      "GET",
+		Base:        server.URL,
+		Endpoint:    "/secure",
+		SuccessCode: 200,
+		RToken:      true,
+	}}
+	actual := Whirlpool(3, reqs, false, "", &KeyChain{}, nil)
+	if actual != 6 {
+		t.Errorf("Expected 6 successes, but got %d successes", actual)
+	}
+}
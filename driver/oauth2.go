@@ -0,0 +1,181 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryDelta is how long before its expiry an OAuth2 access token is refreshed
+const expiryDelta = 10 * time.Second
+
+// OAuth2Config holds the settings used to fetch an OAuth2 access token for requests requiring a token:
+// TokenURL: token endpoint of the authorization server
+// ClientID: client id used for the client credentials grant
+// ClientSecret: client secret used for the client credentials grant
+// Scopes: scopes to request
+// RefreshToken: if set the refresh token grant is used instead of the client credentials grant
+// TLS: TLS settings used to reach the token endpoint, defaults to the top level TLS settings of the requests file
+type OAuth2Config struct {
+	TokenURL     string     `yaml:"token-url"`
+	ClientID     string     `yaml:"client-id"`
+	ClientSecret string     `yaml:"client-secret"`
+	Scopes       []string   `yaml:"scopes"`
+	RefreshToken string     `yaml:"refresh-token"`
+	TLS          *TLSConfig `yaml:"tls"`
+
+	mu          sync.Mutex
+	client      *http.Client
+	accessToken string
+	expiry      time.Time
+	fetches     int
+	fetchTime   time.Duration
+}
+
+// tokenResponse is the JSON response of an OAuth2 token endpoint
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// startOAuth2 fetches the OAuth2 access tokens of the KeyChain and its profiles before a run starts
+func startOAuth2(chain *KeyChain, opts *Options) {
+	for _, keys := range chain.all() {
+		if keys.OAuth2 == nil {
+			continue
+		}
+		tlsConfig := keys.OAuth2.TLS
+		if tlsConfig == nil {
+			tlsConfig = chain.tls
+		}
+		client, err := newClient(tlsConfig, opts)
+		if err != nil {
+			log.Fatalf("Couldn't configure the client for the OAuth2 token endpoint: %v\n", err)
+		}
+		err = keys.OAuth2.start(client)
+		if err != nil {
+			log.Fatalf("Couldn't fetch the OAuth2 access token: %v\n", err)
+		}
+	}
+}
+
+// logOAuth2Stats logs the token fetch statistics of the KeyChain and its profiles
+func logOAuth2Stats(chain *KeyChain) {
+	for _, keys := range chain.all() {
+		if keys.OAuth2 != nil {
+			keys.OAuth2.logStats()
+		}
+	}
+}
+
+// start sets the client used to reach the token endpoint and fetches the first access token
+func (o *OAuth2Config) start(client *http.Client) error {
+	o.mu.Lock()
+	o.client = client
+	o.mu.Unlock()
+	_, err := o.token("")
+	return err
+}
+
+// token returns the cached access token, fetching a new one if there is none, it is about to expire or it is the
+// rejected token. Requests rejected with the same token concurrently trigger a single fetch, the others get the
+// token fetched by the first one
+func (o *OAuth2Config) token(rejected string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	expired := !o.expiry.IsZero() && time.Now().Add(expiryDelta).After(o.expiry)
+	if o.accessToken != "" && !expired && (rejected == "" || rejected != o.accessToken) {
+		return o.accessToken, nil
+	}
+	err := o.fetch()
+	if err != nil {
+		return "", err
+	}
+	return o.accessToken, nil
+}
+
+// fetch requests a new access token from the token endpoint, the caller must hold the lock
+func (o *OAuth2Config) fetch() error {
+	form := url.Values{}
+	if o.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	req, err := http.NewRequest("POST", o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+
+	client := o.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	fetchStart := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	elapsed := time.Since(fetchStart)
+
+	// Record the token fetch latency separately from the requests
+	o.fetches++
+	o.fetchTime += elapsed
+	log.Printf("OAuth2 token fetch from %s %d, %s\n", o.TokenURL, resp.StatusCode, elapsed)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token endpoint responded with status code %d", resp.StatusCode)
+	}
+
+	var token tokenResponse
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return err
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("token endpoint response has no access_token")
+	}
+	o.accessToken = token.AccessToken
+	o.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		o.expiry = fetchStart.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	// Some servers rotate refresh tokens
+	if token.RefreshToken != "" {
+		o.RefreshToken = token.RefreshToken
+	}
+	return nil
+}
+
+// logStats logs how many access tokens were fetched and their average latency
+func (o *OAuth2Config) logStats() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.fetches == 0 {
+		return
+	}
+	log.Printf("OAuth2 token fetches: %d, average latency: %s\n", o.fetches, o.fetchTime/time.Duration(o.fetches))
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// stubOAuth2 is a token endpoint stub issuing numbered access tokens, only the latest one is accepted by the API
+type stubOAuth2 struct {
+	sync.Mutex
+	issued    int
+	expiresIn int
+	grants    []string
+}
+
+func (s *stubOAuth2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	switch r.URL.Path {
+	case "/token":
+		id, secret, _ := r.BasicAuth()
+		if id != "wave" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.issued++
+		s.grants = append(s.grants, r.FormValue("grant_type"))
+		_, _ = fmt.Fprintf(w, `{"access_token": "t%d", "token_type": "Bearer", "expires_in": %d, "refresh_token": "r%d"}`,
+			s.issued, s.expiresIn, s.issued)
+	case "/revoke":
+		// Revokes the current token so that the next request is unauthorized
+		s.issued++
+	default:
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer t%d", s.issued) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	stub := &stubOAuth2{expiresIn: 3600}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	chain := &KeyChain{OAuth2: &OAuth2Config{
+		TokenURL:     server.URL + "/token",
+		ClientID:     "wave",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+	}}
+	reqs := []*Request{{Method: "GET", Base: server.URL, Endpoint: "/coffee", SuccessCode: 200, RToken: true}}
+
+	// The token is fetched once and cached for the whole run
+	actual := Whirlpool(5, reqs, false, "", chain, nil)
+	if actual != 5 || stub.issued != 1 {
+		t.Errorf("Expected 5 successes with 1 token fetch, but got %d successes with %d fetches", actual, stub.issued)
+	}
+
+	// A revoked token is refreshed after the first unauthorized response
+	reqs = []*Request{
+		{Method: "GET", Base: server.URL, Endpoint: "/coffee", SuccessCode: 200, RToken: true},
+		{Method: "POST", Base: server.URL, Endpoint: "/revoke", SuccessCode: 200},
+	}
+	actual = Whirlpool(2, reqs, false, "", chain, nil)
+	if actual != 4 {
+		t.Errorf("Expected 4 successes after refreshing the token, but got %d successes", actual)
+	}
+	if stub.grants[len(stub.grants)-1] != "refresh_token" {
+		t.Errorf("Expected the refresh token grant to be used, but got %s", stub.grants[len(stub.grants)-1])
+	}
+}
+
+func TestOAuth2Expiry(t *testing.T) {
+	stub := &stubOAuth2{expiresIn: 5}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	// Tokens expiring within the expiry delta are fetched again before every request
+	conf := &OAuth2Config{TokenURL: server.URL + "/token", ClientID: "wave", ClientSecret: "s3cret"}
+	chain := &KeyChain{OAuth2: conf}
+	reqs := []*Request{{Method: "GET", Base: server.URL, Endpoint: "/coffee", SuccessCode: 200, RToken: true}}
+	actual := Whirlpool(3, reqs, false, "", chain, nil)
+	if actual != 3 || conf.fetches != 4 {
+		t.Errorf("Expected 3 successes with 4 token fetches, but got %d successes with %d fetches", actual, conf.fetches)
+	}
+
+	// Wrong client credentials
+	conf = &OAuth2Config{TokenURL: server.URL + "/token", ClientID: "wave", ClientSecret: "wrong"}
+	if err := conf.start(http.DefaultClient); err == nil {
+		t.Errorf("Expected an error for wrong client credentials")
+	}
+}
+
+func TestOAuth2RejectedToken(t *testing.T) {
+	stub := &stubOAuth2{expiresIn: 3600}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	conf := &OAuth2Config{TokenURL: server.URL + "/token", ClientID: "wave", ClientSecret: "s3cret"}
+	if err := conf.start(http.DefaultClient); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(server.URL+"/revoke", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	// Every virtual user is rejected with the same token but only one of them fetches a new one
+	chain := &KeyChain{OAuth2: conf}
+	reqs := []*Request{{Method: "GET", Base: server.URL, Endpoint: "/coffee", SuccessCode: 200, RToken: true}}
+	var reported int32
+	opts := &Options{OnResponse: func(req *Request, resp *http.Response) {
+		atomic.AddInt32(&reported, 1)
+	}}
+	actual := Splash(10, reqs, true, "", chain, opts)
+	if actual != 10 || stub.issued != 3 {
+		t.Errorf("Expected 10 successes with 1 token fetch after the revocation, but got %d successes with %d "+
+			"fetches", actual, stub.issued-2)
+	}
+	// The rejected attempts aren't reported
+	if reported != 10 {
+		t.Errorf("Expected the final response of the 10 requests to be reported, but got %d", reported)
+	}
+
+	// A token rejected before the last fetch doesn't trigger another one
+	token, err := conf.token("t1")
+	if err != nil || token != "t3" || stub.issued != 3 {
+		t.Errorf("Expected the cached token t3 without a fetch, but got %s with %d fetches", token, stub.issued)
+	}
+}
+
+func TestOAuth2TLS(t *testing.T) {
+	stub := &stubOAuth2{expiresIn: 3600}
+	server := httptest.NewTLSServer(stub)
+	defer server.Close()
+	caFile := writeServerCA(t, server)
+
+	// The token endpoint is reached with the top level TLS settings of the requests file
+	dir := t.TempDir()
+	reqFile := filepath.Join(dir, "reqs.yaml")
+	credFile := filepath.Join(dir, "cred.yaml")
+	requests := "tls:\n  ca-file: " + caFile + "\nrequest-1:\n  method: GET\n  base: " + server.URL +
+		"\n  endpoint: /coffee\n  success-code: 200\n  r-token: true\n"
+	credentials := "oauth2:\n  token-url: " + server.URL + "/token\n  client-id: wave\n  client-secret: s3cret\n"
+	if err := ioutil.WriteFile(reqFile, []byte(requests), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(credFile, []byte(credentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reqs, chain := New(reqFile, credFile)
+	if actual := Whirlpool(1, reqs, false, "", chain, nil); actual != 1 {
+		t.Errorf("Expected 1 success with the top level TLS settings, but got %d", actual)
+	}
+
+	// The TLS settings of the credentials file take precedence
+	chain = &KeyChain{OAuth2: &OAuth2Config{
+		TokenURL:     server.URL + "/token",
+		ClientID:     "wave",
+		ClientSecret: "s3cret",
+		TLS:          &TLSConfig{CAFile: caFile},
+	}}
+	startOAuth2(chain, nil)
+	if token, err := chain.OAuth2.token(""); err != nil || token == "" {
+		t.Errorf("Expected a token from the TLS token endpoint, but got %v", err)
+	}
+}
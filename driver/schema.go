@@ -0,0 +1,226 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema is the subset of JSON Schema, as used by OpenAPI 3 response schemas, that response bodies are validated
+// against. Keywords it doesn't know, such as format, are ignored
+type jsonSchema struct {
+	Type                 schemaType             `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *json.RawMessage       `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Nullable             bool                   `json:"nullable"`
+	AllOf                []*jsonSchema          `json:"allOf"`
+	AnyOf                []*jsonSchema          `json:"anyOf"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+}
+
+// schemaType is the type keyword of a schema, which is either a single type or a list of types
+type schemaType []string
+
+// UnmarshalJSON reads a single type or a list of types
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// parseSchema parses a JSON schema
+func parseSchema(data []byte) (*jsonSchema, error) {
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// validateJSON validates a JSON document against the schema and returns the violations found
+func (s *jsonSchema) validateJSON(data []byte) []string {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []string{fmt.Sprintf("response body isn't valid JSON: %v", err)}
+	}
+	return s.validate(value, "$")
+}
+
+// validate validates a decoded JSON value found at the path against the schema
+func (s *jsonSchema) validate(value interface{}, path string) []string {
+	if s == nil {
+		return nil
+	}
+	if value == nil && s.Nullable {
+		return nil
+	}
+
+	var errs []string
+	for _, sub := range s.AllOf {
+		errs = append(errs, sub.validate(value, path)...)
+	}
+	if len(s.AnyOf) > 0 && s.matching(s.AnyOf, value, path) == 0 {
+		errs = append(errs, fmt.Sprintf("%s doesn't match any of the anyOf schemas", path))
+	}
+	if len(s.OneOf) > 0 {
+		if n := s.matching(s.OneOf, value, path); n != 1 {
+			errs = append(errs, fmt.Sprintf("%s matches %d of the oneOf schemas instead of 1", path, n))
+		}
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, option := range s.Enum {
+			if reflect.DeepEqual(option, value) || fmt.Sprint(option) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s is %v, which isn't one of %v", path, value, s.Enum))
+		}
+	}
+	if len(s.Type) > 0 && !s.hasType(value) {
+		return append(errs, fmt.Sprintf("%s should be of type %s, but is %s", path, strings.Join(s.Type, " or "),
+			jsonType(value)))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, s.validateObject(v, path)...)
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			errs = append(errs, fmt.Sprintf("%s has %d items, less than %d", path, len(v), *s.MinItems))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			errs = append(errs, fmt.Sprintf("%s has %d items, more than %d", path, len(v), *s.MaxItems))
+		}
+		for i, item := range v {
+			errs = append(errs, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			errs = append(errs, fmt.Sprintf("%s is shorter than %d characters", path, *s.MinLength))
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			errs = append(errs, fmt.Sprintf("%s is longer than %d characters", path, *s.MaxLength))
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				errs = append(errs, fmt.Sprintf("%s doesn't match the pattern %s", path, s.Pattern))
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			errs = append(errs, fmt.Sprintf("%s is %v, less than %v", path, v, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			errs = append(errs, fmt.Sprintf("%s is %v, more than %v", path, v, *s.Maximum))
+		}
+	}
+	return errs
+}
+
+// validateObject validates the properties of an object
+func (s *jsonSchema) validateObject(object map[string]interface{}, path string) []string {
+	var errs []string
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			errs = append(errs, fmt.Sprintf("%s is missing the required property %s", path, name))
+		}
+	}
+
+	// Validate the properties in a stable order
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// additionalProperties is either a boolean or a schema
+	allowed, additional := true, (*jsonSchema)(nil)
+	if s.AdditionalProperties != nil {
+		if err := json.Unmarshal(*s.AdditionalProperties, &allowed); err != nil {
+			allowed = true
+			additional, _ = parseSchema(*s.AdditionalProperties)
+		}
+	}
+	for _, name := range names {
+		if property, ok := s.Properties[name]; ok {
+			errs = append(errs, property.validate(object[name], path+"."+name)...)
+		} else if !allowed {
+			errs = append(errs, fmt.Sprintf("%s has the unexpected property %s", path, name))
+		} else {
+			errs = append(errs, additional.validate(object[name], path+"."+name)...)
+		}
+	}
+	return errs
+}
+
+// matching returns the number of schemas the value is valid against
+func (s *jsonSchema) matching(schemas []*jsonSchema, value interface{}, path string) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.validate(value, path)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// hasType reports whether the value is of one of the types of the schema
+func (s *jsonSchema) hasType(value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range s.Type {
+		if t == actual || (t == "number" && actual == "integer") || (t == "null" && value == nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
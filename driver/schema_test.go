@@ -0,0 +1,52 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"testing"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["id", "name"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+		"role": {"type": "string", "enum": ["admin", "user"]},
+		"email": {"type": "string", "nullable": true},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+		"score": {"oneOf": [{"type": "integer"}, {"type": "string"}]}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := parseSchema([]byte(userSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body   string
+		errors int
+	}{
+		{`{"id": 1, "name": "wave", "role": "admin", "email": null, "tags": ["a"], "score": 3}`, 0},
+		{`{"id": 1, "name": "wave", "score": "high"}`, 0},
+		{`{"name": "wave"}`, 1},
+		{`{"id": 1.5, "name": "wave"}`, 1},
+		{`{"id": 0, "name": "Wave"}`, 2},
+		{`{"id": 1, "name": "wave", "role": "owner", "tags": ["a", "b", 3]}`, 3},
+		{`{"id": 1, "name": "wave", "extra": true}`, 1},
+		{`{"id": 1, "name": "wave", "score": true}`, 1},
+		{`[]`, 1},
+		{`not json`, 1},
+	}
+	for _, test := range tests {
+		errs := schema.validateJSON([]byte(test.body))
+		if len(errs) != test.errors {
+			t.Errorf("%s: expected %d errors, but got %q", test.body, test.errors, errs)
+		}
+	}
+}
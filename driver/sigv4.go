@@ -0,0 +1,187 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSCredentials holds the credentials used to sign requests with AWS Signature Version 4:
+// AccessKeyID: AWS access key id
+// SecretAccessKey: AWS secret access key
+// SessionToken: session token of temporary credentials
+// Region: AWS region of the API, such as us-east-1
+// Service: signing name of the service, such as execute-api for API Gateway
+type AWSCredentials struct {
+	AccessKeyID     string `yaml:"access-key-id"`
+	SecretAccessKey string `yaml:"secret-access-key"`
+	SessionToken    string `yaml:"session-token"`
+	Region          string `yaml:"region"`
+	Service         string `yaml:"service"`
+}
+
+// sigV4Algorithm is the signing algorithm of AWS Signature Version 4
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// sigV4Auth signs the request with the AWS credentials of the KeyChain, the region and service of the auth settings
+// override the ones of the credentials
+func sigV4Auth(req *http.Request, body []byte, conf *AuthConfig, keys *KeyChain) error {
+	if keys.AWS == nil || keys.AWS.AccessKeyID == "" || keys.AWS.SecretAccessKey == "" {
+		return fmt.Errorf("sigv4 auth requires aws credentials in the credentials file")
+	}
+	region, service := keys.AWS.Region, keys.AWS.Service
+	if conf.Region != "" {
+		region = conf.Region
+	}
+	if conf.Service != "" {
+		service = conf.Service
+	}
+	if region == "" || service == "" {
+		return fmt.Errorf("sigv4 auth requires a region and service")
+	}
+
+	creds := *keys.AWS
+	creds.Region, creds.Service = region, service
+	signV4(req, body, &creds, time.Now())
+	return nil
+}
+
+// signV4 adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers of AWS Signature Version 4 to a request
+// whose body and other headers are final
+func signV4(req *http.Request, body []byte, creds *AWSCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := strings.Join([]string{amzDate[:8], creds.Region, creds.Service, "aws4_request"}, "/")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	payloadHash := sha256Hex(body)
+	if creds.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// Build the canonical request
+	headers, signedHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req, creds.Service),
+		canonicalQuery(req),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	// Sign it with the derived signing key
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), amzDate[:8])
+	key = hmacSHA256(key, creds.Region)
+	key = hmacSHA256(key, creds.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalURI returns the normalized and URI encoded path, S3 paths are neither normalized nor encoded twice
+func canonicalURI(req *http.Request, service string) string {
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return uri
+	}
+	cleaned := path.Clean(uri)
+	if strings.HasSuffix(uri, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	segments := strings.Split(cleaned, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the query parameters sorted by name and value and URI encoded
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// canonicalHeaders returns the canonical headers and the semicolon separated names of the signed headers, every
+// header of the request is signed along with the host
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": strings.TrimSpace(host)}
+	for name, vals := range req.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" {
+			continue
+		}
+		trimmed := make([]string, len(vals))
+		for i, value := range vals {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+	return headers.String(), strings.Join(names, ";")
+}
+
+// uriEncode percent encodes every byte except the unreserved characters of RFC 3986
+func uriEncode(s string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			encoded.WriteByte(c)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return encoded.String()
+}
+
+// sha256Hex returns the hex encoded SHA256 hash of the data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data using the key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
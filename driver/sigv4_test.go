@@ -0,0 +1,67 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignV4 checks the signer against the get-vanilla, get-vanilla-query-order-key-case and
+// post-x-www-form-urlencoded cases of the AWS Signature Version 4 test suite
+func TestSignV4(t *testing.T) {
+	creds := &AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	scope := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "
+
+	cases := []struct {
+		method, url, contentType, body, expected string
+	}{
+		{"GET", "https://example.amazonaws.com/", "", "", scope + "SignedHeaders=host;x-amz-date, " +
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "", scope +
+			"SignedHeaders=host;x-amz-date, " +
+			"Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1", scope +
+			"SignedHeaders=content-type;host;x-amz-date, " +
+			"Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.url, strings.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		signV4(req, []byte(c.body), creds, now)
+		if actual := req.Header.Get("Authorization"); actual != c.expected {
+			t.Errorf("%s %s: expected %s, but got %s", c.method, c.url, c.expected, actual)
+		}
+	}
+}
+
+func TestSigV4Auth(t *testing.T) {
+	req := &Request{Method: "GET", Base: "https://abc.execute-api.eu-west-1.amazonaws.com", Endpoint: "/prod/pets",
+		Auth: &AuthConfig{Type: "sigv4", Region: "eu-west-1", Service: "execute-api"}}
+	if _, err := req.prepareRequest(&KeyChain{}); err == nil {
+		t.Errorf("Expected an error without aws credentials")
+	}
+
+	keys := &KeyChain{AWS: &AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "t0k3n"}}
+	r, err := req.prepareRequest(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.Contains(auth, "/eu-west-1/execute-api/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token") {
+		t.Errorf("Expected a signature scoped to eu-west-1 and execute-api, but got %s", auth)
+	}
+}
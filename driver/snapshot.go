@@ -0,0 +1,232 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// snapshotDir is the directory the snapshots of requests without an expect file are written to
+const snapshotDir = "./snapshots"
+
+// maxDiffLines bounds the number of lines of a diff that are logged
+const maxDiffLines = 40
+
+// nonSnapshotName matches the characters replaced when naming a snapshot file after its request
+var nonSnapshotName = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// snapshotPath returns the path of the snapshot of a request without an expect file, named after its method and
+// endpoint
+func (r *Request) snapshotPath() string {
+	name := strings.Trim(nonSnapshotName.ReplaceAllString(r.Method+"-"+r.Endpoint, "-"), "-")
+	return snapshotDir + "/" + strings.ToLower(name) + ".json"
+}
+
+// loadSnapshots points the snapshot requests without an expect file at their snapshot files and reads the ones
+// that exist
+func loadSnapshots(reqs []*Request, key KeySource) {
+	for _, req := range reqs {
+		if !req.Snapshot || req.ExpectFile != "" {
+			continue
+		}
+		req.ExpectFile = req.snapshotPath()
+		if _, err := os.Stat(req.ExpectFile); errors.Is(err, os.ErrNotExist) {
+			req.snapshotMissing = true
+			continue
+		}
+		req.expectedBody = jsonToByte(req.ExpectFile, key)
+	}
+}
+
+// requireExpectFiles exits if the expect file of a request doesn't exist, unless the request is a snapshot or the
+// snapshots are being updated, which writes it
+func requireExpectFiles(reqs []*Request, update bool) {
+	for _, req := range reqs {
+		if req.snapshotMissing && !req.Snapshot && !update {
+			log.Fatalf("Couldn't open json file at %s\n", req.ExpectFile)
+		}
+	}
+}
+
+// updateSnapshot writes the pretty-printed response body to the expect file of the request, encrypting it again with
+// the passphrase of the KeySource if it was encrypted
+func (r *Request) updateSnapshot(body []byte, key KeySource) error {
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, body, "", "    "); err != nil {
+		return fmt.Errorf("the response body isn't valid JSON: %v", err)
+	}
+	pretty.WriteByte('\n')
+	data := pretty.Bytes()
+
+	perm := os.FileMode(0644)
+	previous, err := ioutil.ReadFile(r.ExpectFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if info, err := os.Stat(r.ExpectFile); err == nil {
+			perm = info.Mode().Perm()
+		}
+	}
+	if isEncrypted(previous) {
+		if key == nil {
+			return fmt.Errorf("%s is encrypted, a passphrase is needed to update it", r.ExpectFile)
+		}
+		passphrase, err := key()
+		if err != nil {
+			return err
+		}
+		if data, err = encryptBytes(data, passphrase); err != nil {
+			return err
+		}
+		perm = secretPerm
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.ExpectFile), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(r.ExpectFile, data, perm); err != nil {
+		return err
+	}
+	r.expectedBody = pretty.Bytes()
+	r.snapshotMissing = false
+	return nil
+}
+
+// compareBody compares the response body with the expected body, leaving out the ignored fields of the request. It
+// returns a diff of the pretty-printed bodies if they don't match
+func (r *Request) compareBody(body []byte) (bool, []string) {
+	var expected, actual interface{}
+	if err := json.Unmarshal(r.expectedBody, &expected); err != nil {
+		return false, []string{fmt.Sprintf("the expected body isn't valid JSON: %v", err)}
+	}
+	if err := json.Unmarshal(body, &actual); err != nil {
+		return false, []string{fmt.Sprintf("the response body isn't valid JSON: %v", err)}
+	}
+	for _, field := range r.IgnoreFields {
+		path := strings.Split(field, ".")
+		expected = removeField(expected, path)
+		actual = removeField(actual, path)
+	}
+	if reflect.DeepEqual(expected, actual) {
+		return true, nil
+	}
+	return false, diffLines(prettyLines(expected), prettyLines(actual))
+}
+
+// removeField removes the field at the dot separated path from a decoded JSON value, applying the rest of the path to
+// every item of the arrays it goes through
+func removeField(value interface{}, path []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(v, path[0])
+		} else if child, ok := v[path[0]]; ok {
+			v[path[0]] = removeField(child, path[1:])
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = removeField(item, path)
+		}
+	}
+	return value
+}
+
+// prettyLines returns the lines of a decoded JSON value pretty-printed with sorted keys
+func prettyLines(value interface{}) []string {
+	data, _ := json.MarshalIndent(value, "", "    ")
+	return strings.Split(string(data), "\n")
+}
+
+// diffLines returns a line diff of the expected and actual lines, prefixing removed lines with - and added lines with +
+func diffLines(expected, actual []string) []string {
+	// Skip the common prefix and suffix
+	start := 0
+	for start < len(expected) && start < len(actual) && expected[start] == actual[start] {
+		start++
+	}
+	end := 0
+	for end < len(expected)-start && end < len(actual)-start &&
+		expected[len(expected)-1-end] == actual[len(actual)-1-end] {
+		end++
+	}
+	a, b := expected[start:len(expected)-end], actual[start:len(actual)-end]
+
+	var diff []string
+	if start > 0 {
+		diff = append(diff, "  "+expected[start-1])
+	}
+	// Fall back to removing and adding every line if the longest common subsequence is too large to compute
+	if len(a)*len(b) > 1000000 {
+		for _, line := range a {
+			diff = append(diff, "- "+line)
+		}
+		for _, line := range b {
+			diff = append(diff, "+ "+line)
+		}
+	} else {
+		diff = append(diff, lcsDiff(a, b)...)
+	}
+	if end > 0 {
+		diff = append(diff, "  "+expected[len(expected)-end])
+	}
+	if len(diff) > maxDiffLines {
+		diff = append(diff[:maxDiffLines], fmt.Sprintf("... %d more lines", len(diff)-maxDiffLines))
+	}
+	return diff
+}
+
+// lcsDiff returns a line diff of two slices of lines using their longest common subsequence
+func lcsDiff(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff = append(diff, "  "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
+
+// logDiff logs the diff of a response body that doesn't match its expected body
+func (r *Request) logDiff(diff []string) {
+	log.Printf("Response JSON body does NOT match expected JSON body %s (- expected, + actual):\n\t%s\n",
+		r.ExpectFile, strings.Join(diff, "\n\t"))
+}
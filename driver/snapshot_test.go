@@ -0,0 +1,181 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"encoding/json"
+	"github.com/fercevik729/Wave/echoserver"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	server := echoserver.New(nil)
+	defer server.Close()
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	requests := `snapshot:
+  method: GET
+  base: ` + server.URL + `
+  endpoint: /echo?page=1
+  success-code: 200
+  snapshot: true
+  ignore-fields: [headers]
+expected:
+  method: POST
+  base: ` + server.URL + `
+  endpoint: /echo
+  success-code: 200
+  data-file: ./post.json
+  expect-file: ./expect.json
+  ignore-fields: [headers]
+`
+	files := map[string]string{
+		"reqs.yaml":   requests,
+		"cred.yaml":   "user: \"developer\"\n",
+		"post.json":   `{"name": "wave"}`,
+		"expect.json": `{"method": "POST", "body": {"name": "stale"}}`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(name, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The snapshot is missing and the expect file is stale
+	reqs, chain := New("reqs.yaml", "cred.yaml")
+	if reqs[0].ExpectFile != "./snapshots/get-echo-page-1.json" {
+		t.Errorf("Expected the snapshot to be named after the request, but got %s", reqs[0].ExpectFile)
+	}
+	if successes := Whirlpool(1, reqs, false, "", chain, nil); successes != 0 {
+		t.Errorf("Expected no successes before the snapshots are updated, but got %d", successes)
+	}
+
+	// Updating writes the pretty-printed response bodies
+	if successes := Whirlpool(2, reqs, false, "", chain, &Options{UpdateSnapshots: true}); successes != 4 {
+		t.Errorf("Expected 4 successes while updating the snapshots, but got %d", successes)
+	}
+	snapshot, err := ioutil.ReadFile(filepath.Join("snapshots", "get-echo-page-1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var echoed map[string]interface{}
+	if err := json.Unmarshal(snapshot, &echoed); err != nil || echoed["method"] != "GET" {
+		t.Errorf("Expected the snapshot to hold the response body, but got %s", snapshot)
+	}
+	expect, _ := ioutil.ReadFile("expect.json")
+	if !json.Valid(expect) || expect[len(expect)-1] != '\n' {
+		t.Errorf("Expected the expect file to be pretty-printed, but got %s", expect)
+	}
+
+	// The snapshots match once they're written
+	reqs, chain = New("reqs.yaml", "cred.yaml")
+	if successes := Whirlpool(1, reqs, false, "", chain, nil); successes != 2 {
+		t.Errorf("Expected 2 successes after the snapshots were updated, but got %d", successes)
+	}
+}
+
+func TestSnapshotsSharedFile(t *testing.T) {
+	server := echoserver.New(nil)
+	defer server.Close()
+	dir := t.TempDir()
+
+	write := func(ignored string) string {
+		reqFile := filepath.Join(dir, "reqs.yaml")
+		requests := `ids:
+  method: GET
+  base: ` + server.URL + `
+  endpoint: /echo?id={id}
+  success-code: 200
+  id-range: ["1", "3"]
+  expect-file: ` + filepath.Join(dir, "ids.json") + `
+  ignore-fields: [` + ignored + `]
+`
+		if err := ioutil.WriteFile(reqFile, []byte(requests), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return reqFile
+	}
+	credFile := filepath.Join(dir, "cred.yaml")
+	if err := ioutil.WriteFile(credFile, []byte("user: \"developer\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first id writes the snapshot, the other ids are compared against it
+	reqs, chain := New(write("headers"), credFile)
+	if successes := Whirlpool(1, reqs, false, "", chain, &Options{UpdateSnapshots: true}); successes != 1 {
+		t.Errorf("Expected only the first id to match the snapshot, but got %d successes", successes)
+	}
+	reqs, chain = New(write("headers, query"), credFile)
+	if successes := Whirlpool(1, reqs, false, "", chain, &Options{UpdateSnapshots: true}); successes != 3 {
+		t.Errorf("Expected every id to match the snapshot without its query, but got %d successes", successes)
+	}
+}
+
+func TestSnapshotsMissingExpectFile(t *testing.T) {
+	server := echoserver.New(nil)
+	defer server.Close()
+	dir := t.TempDir()
+	reqFile, credFile := filepath.Join(dir, "reqs.yaml"), filepath.Join(dir, "cred.yaml")
+	expectFile := filepath.Join(dir, "data", "new.json")
+	requests := `new:
+  method: GET
+  base: ` + server.URL + `
+  endpoint: /echo
+  success-code: 200
+  expect-file: ` + expectFile + `
+  ignore-fields: [headers]
+`
+	if err := ioutil.WriteFile(reqFile, []byte(requests), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(credFile, []byte("user: \"developer\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Updating the snapshots writes the expect file that doesn't exist yet
+	reqs, chain := New(reqFile, credFile)
+	if successes := Whirlpool(1, reqs, false, "", chain, &Options{UpdateSnapshots: true}); successes != 1 {
+		t.Errorf("Expected the new expect file to be written, but got %d successes", successes)
+	}
+	expect, err := ioutil.ReadFile(expectFile)
+	if err != nil || !json.Valid(expect) {
+		t.Fatalf("Expected the expect file to hold the response body, but got %s and %v", expect, err)
+	}
+	reqs, chain = New(reqFile, credFile)
+	if successes := Whirlpool(1, reqs, false, "", chain, nil); successes != 1 {
+		t.Errorf("Expected the request to match its new expect file, but got %d successes", successes)
+	}
+}
+
+func TestCompareBody(t *testing.T) {
+	req := &Request{
+		expectedBody: []byte(`{"id": 1, "name": "wave", "items": [{"id": 1, "size": 2}], "meta": {"at": "monday"}}`),
+		IgnoreFields: []string{"id", "items.id", "meta.at"},
+	}
+	if ok, diff := req.compareBody([]byte(`{"id": 2, "name": "wave", "items": [{"id": 7, "size": 2}],
+		"meta": {"at": "tuesday"}}`)); !ok {
+		t.Errorf("Expected the ignored fields to be left out, but got the diff %q", diff)
+	}
+
+	ok, diff := req.compareBody([]byte(`{"id": 1, "name": "surf", "items": [{"id": 1, "size": 2}], "meta": {}}`))
+	expected := []string{
+		"      \"meta\": {},",
+		"-     \"name\": \"wave\"",
+		"+     \"name\": \"surf\"",
+		"  }",
+	}
+	if ok || !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected the diff %q, but got %q", expected, diff)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+// TLSConfig holds the TLS settings used when connecting to an API. It can be set for every request at the top of the
+// requests YAML file under the "tls" key or for a single request with its own "tls" field:
+// CAFile: filepath to a PEM bundle of certificate authorities used to verify the server
+// CertFile: filepath to a PEM client certificate for mutual TLS
+// KeyFile: filepath to the PEM private key of the client certificate, it may be encrypted with the protect command
+// KeyPassFile: filepath to a key file containing the passphrase of an encrypted KeyFile
+// ServerName: overrides the server name used to verify the server certificate
+// MinVersion: minimum TLS version to accept, one of 1.0, 1.1, 1.2 or 1.3
+// InsecureSkipVerify: disables verification of the server certificate
+type TLSConfig struct {
+	CAFile             string `yaml:"ca-file,omitempty"`
+	CertFile           string `yaml:"cert-file,omitempty"`
+	KeyFile            string `yaml:"key-file,omitempty"`
+	KeyPassFile        string `yaml:"key-passfile,omitempty"`
+	ServerName         string `yaml:"server-name,omitempty"`
+	MinVersion         string `yaml:"min-version,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify,omitempty"`
+}
+
+// tlsVersions maps the accepted min-version values to their crypto/tls constants
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// build converts the TLSConfig into a *tls.Config, loading any certificate authorities and client key pairs
+func (t *TLSConfig) build() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.InsecureSkipVerify {
+		log.Println("WARNING: insecure-skip-verify is enabled, server certificates will NOT be verified. " +
+			"Never use this setting against production APIs")
+	}
+
+	// Set the minimum version
+	if t.MinVersion != "" {
+		version, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS min-version %q, use one of 1.0, 1.1, 1.2 or 1.3", t.MinVersion)
+		}
+		conf.MinVersion = version
+	}
+
+	// Load the certificate authorities
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", t.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	// Load the client certificate for mutual TLS
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("both cert-file and key-file must be set for client certificates")
+		}
+		cert, err := t.loadKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// loadKeyPair reads the client certificate and key, decrypting the key if it was encrypted with the protect command
+func (t *TLSConfig) loadKeyPair() (tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(t.CertFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := ioutil.ReadFile(t.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if isEncrypted(keyPEM) {
+		if t.KeyPassFile == "" {
+			return tls.Certificate{}, fmt.Errorf("key file %s is encrypted but no key-passfile was given", t.KeyFile)
+		}
+		pass, err := ioutil.ReadFile(t.KeyPassFile)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		keyPEM, err = decryptBytes(keyPEM, strings.TrimRight(string(pass), "\r\n"))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("couldn't decrypt key file %s: %v", t.KeyFile, err)
+		}
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
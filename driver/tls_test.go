@@ -0,0 +1,161 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeServerCA writes the certificate of a TLS test server to a PEM file and returns its path
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile
+}
+
+// writeClientCert creates a self-signed client certificate and returns the paths of the certificate and key files
+func writeClientCert(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "wave-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func TestTLSCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Without the CA the server certificate can't be verified
+	client, err := newClient(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("Expected an unknown authority error without a CA file")
+	}
+
+	client, err = newClient(&TLSConfig{CAFile: writeServerCA(t, server)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the CA file to verify the server, but got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	cert, certFile, keyFile := writeClientCert(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writeServerCA(t, server)
+
+	// Plain key file
+	client, err := newClient(&TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected mutual TLS to succeed, but got %v", err)
+	}
+	resp.Body.Close()
+
+	// Key file encrypted with the protect command
+	passFile := filepath.Join(t.TempDir(), "key.txt")
+	if err := ioutil.WriteFile(passFile, []byte("mysecretpassword\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encrypt(keyFile, "mysecretpassword"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newClient(&TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, nil); err == nil {
+		t.Errorf("Expected an error for an encrypted key file without a key-passfile")
+	}
+	client, err = newClient(&TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, KeyPassFile: passFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected mutual TLS with an encrypted key to succeed, but got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	if _, err := newClient(&TLSConfig{MinVersion: "2.0"}, nil); err == nil {
+		t.Errorf("Expected an error for an unsupported min-version")
+	}
+}
+
+func TestTLSReservedName(t *testing.T) {
+	if _, globalTLS, err := parseRequests([]byte("tls:\n  ca-file: \"./ca.pem\"\n")); err != nil ||
+		globalTLS.CAFile != "./ca.pem" {
+		t.Errorf("Expected the top level TLS settings, but got %+v and %v", globalTLS, err)
+	}
+	// A request named tls isn't silently read as TLS settings
+	requests := "tls:\n  method: \"GET\"\n  base: \"https://api.internal\"\n  endpoint: \"/health\"\n"
+	if _, _, err := parseRequests([]byte(requests)); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("Expected an error for a request named tls, but got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/net/http/httpproxy"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// newClient returns an *http.Client using the TLS settings and run options if any are given
+func newClient(t *TLSConfig, opts *Options) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if t != nil {
+		conf, err := t.build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = conf
+	}
+	if opts != nil {
+		if opts.Proxy != "" {
+			proxy, err := proxyFunc(opts.Proxy)
+			if err != nil {
+				return nil, err
+			}
+			transport.Proxy = proxy
+		}
+		if len(opts.Resolve) > 0 {
+			dial, err := resolveDialer(opts.Resolve)
+			if err != nil {
+				return nil, err
+			}
+			transport.DialContext = dial
+		}
+	}
+
+	return &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: transport,
+	}, nil
+}
+
+// newClients returns an *http.Client for every distinct TLS configuration used by the requests
+func newClients(reqs []*Request, opts *Options) map[*TLSConfig]*http.Client {
+	clients := make(map[*TLSConfig]*http.Client)
+	for _, req := range reqs {
+		if _, ok := clients[req.TLS]; ok {
+			continue
+		}
+		client, err := newClient(req.TLS, opts)
+		if err != nil {
+			log.Fatalf("Couldn't configure the client for %s: %v\n", req, err)
+		}
+		clients[req.TLS] = client
+	}
+
+	return clients
+}
+
+// proxyFunc returns a proxy function sending every request through the proxy, except for hosts listed in NO_PROXY
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	if _, err := url.Parse(proxy); err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %v", proxy, err)
+	}
+
+	conf := httpproxy.FromEnvironment()
+	conf.HTTPProxy = proxy
+	conf.HTTPSProxy = proxy
+	match := conf.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return match(req.URL)
+	}, nil
+}
+
+// resolveDialer returns a dial function that connects to the pinned address of any host:port:addr entry and
+// resolves every other address normally
+func resolveDialer(entries []string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	pinned := make(map[string]string)
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid resolve entry %q, use host:port:addr", entry)
+		}
+		addr := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid address %q in resolve entry %q", addr, entry)
+		}
+		pinned[net.JoinHostPort(parts[0], parts[1])] = net.JoinHostPort(addr, parts[1])
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if pin, ok := pinned[addr]; ok {
+			addr = pin
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}, nil
+}
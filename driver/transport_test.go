@@ -0,0 +1,71 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	port := u.Port()
+
+	client, err := newClient(nil, &Options{Resolve: []string{"api.example.com:" + port + ":127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://api.example.com:" + port + "/health")
+	if err != nil {
+		t.Fatalf("Expected the pinned address to be used, but got %v", err)
+	}
+	resp.Body.Close()
+	if host != "api.example.com:"+port {
+		t.Errorf("Expected the Host header to keep the original host, but got %s", host)
+	}
+
+	if _, err := newClient(nil, &Options{Resolve: []string{"api.example.com:443"}}); err == nil {
+		t.Errorf("Expected an error for a resolve entry without an address")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := newClient(nil, &Options{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://api.example.com/coffee/hot")
+	if err != nil {
+		t.Fatalf("Expected the request to go through the proxy, but got %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://api.example.com/coffee/hot" {
+		t.Errorf("Expected the proxy to receive http://api.example.com/coffee/hot, but got %s", proxied)
+	}
+
+	// Hosts listed in NO_PROXY bypass the proxy
+	t.Setenv("NO_PROXY", "api.example.com")
+	proxyURL, err := proxyFunc(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "http://api.example.com/coffee/hot", nil)
+	if u, _ := proxyURL(req); u != nil {
+		t.Errorf("Expected NO_PROXY hosts to bypass the proxy, but got %s", u)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package echoserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultToken is the token handed out by the auth endpoints if Options doesn't set one
+const DefaultToken = "wave-token"
+
+// maxDelay and maxBytes bound the delay and payload endpoints so that a typo doesn't hang or exhaust the server
+const (
+	maxDelay = time.Minute
+	maxBytes = 100 << 20
+)
+
+// Options holds the settings of an echo server:
+// Token: token handed out by /auth/token and required by /auth/protected, defaults to DefaultToken
+type Options struct {
+	Token string
+}
+
+// Handler returns the handler of an echo server with the endpoints:
+// /echo: responds with the method, path, query, headers and body of the request as JSON
+// /delay/{duration}: echoes the request after waiting for the duration, such as 250ms
+// /status/{code}: echoes the request with the status code
+// /auth/token: responds with {"token": token}
+// /auth/protected: echoes the request if it has an "Authorization: Bearer token" header, responds with 401 otherwise
+// /bytes/{n}: responds with a JSON object of exactly n bytes, at least 11
+func Handler(opts *Options) http.Handler {
+	token := DefaultToken
+	if opts != nil && opts.Token != "" {
+		token = opts.Token
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		echo(w, r, http.StatusOK)
+	})
+	mux.HandleFunc("/delay/", func(w http.ResponseWriter, r *http.Request) {
+		delay, err := time.ParseDuration(strings.TrimPrefix(r.URL.Path, "/delay/"))
+		if err != nil || delay < 0 || delay > maxDelay {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("the delay should be a duration up to %s", maxDelay))
+			return
+		}
+		select {
+		case <-time.After(delay):
+			echo(w, r, http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/status/", func(w http.ResponseWriter, r *http.Request) {
+		code, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/status/"))
+		if err != nil || code < 100 || code > 599 {
+			writeError(w, http.StatusBadRequest, "the status code should be between 100 and 599")
+			return
+		}
+		echo(w, r, code)
+	})
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"token": token})
+	})
+	mux.HandleFunc("/auth/protected", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		echo(w, r, http.StatusOK)
+	})
+	mux.HandleFunc("/bytes/", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/bytes/"))
+		if err != nil || n < len(`{"data":""}`) || n > maxBytes {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("the size should be between 11 and %d bytes", maxBytes))
+			return
+		}
+		body := `{"data":"` + strings.Repeat("a", n-len(`{"data":""}`)) + `"}`
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(n))
+		_, _ = w.Write([]byte(body))
+	})
+	return mux
+}
+
+// New starts an echo server on a local port for tests, it should be closed once the test completes
+func New(opts *Options) *httptest.Server {
+	return httptest.NewServer(Handler(opts))
+}
+
+// echoed is the JSON response of the echo endpoints. Bodies that are valid JSON are echoed as JSON, other bodies as
+// a string
+type echoed struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   map[string][]string `json:"query"`
+	Headers map[string]string   `json:"headers"`
+	Body    interface{}         `json:"body"`
+}
+
+// echo responds with the request
+func echo(w http.ResponseWriter, r *http.Request, code int) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	e := echoed{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.Query(),
+		Headers: make(map[string]string, len(r.Header)),
+	}
+	for name, values := range r.Header {
+		e.Headers[name] = strings.Join(values, ", ")
+	}
+	if len(body) > 0 {
+		e.Body = string(body)
+		if json.Valid(body) {
+			e.Body = json.RawMessage(body)
+		}
+	}
+	writeJSON(w, code, e)
+}
+
+// writeError responds with {"error": message}
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// writeJSON responds with the value as JSON
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package echoserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpoints(t *testing.T) {
+	server := New(&Options{Token: "secret"})
+	defer server.Close()
+
+	send := func(method, path, body string, header http.Header) (int, []byte) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		for name := range header {
+			req.Header.Set(name, header.Get(name))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, data
+	}
+
+	// Echo
+	code, data := send("POST", "/echo?page=2", `{"foo1": "bar1"}`, http.Header{"X-Trace": {"1"}})
+	var e echoed
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := e.Body.(map[string]interface{})
+	if code != 200 || e.Method != "POST" || e.Path != "/echo" || e.Query["page"][0] != "2" ||
+		e.Headers["X-Trace"] != "1" || body["foo1"] != "bar1" {
+		t.Errorf("Expected the request to be echoed, but got %d %s", code, data)
+	}
+
+	// Delay
+	start := time.Now()
+	if code, _ := send("GET", "/delay/100ms", "", nil); code != 200 || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("Expected a delayed response")
+	}
+	if code, _ := send("GET", "/delay/forever", "", nil); code != 400 {
+		t.Errorf("Expected 400 for an invalid delay, but got %d", code)
+	}
+
+	// Status codes
+	if code, _ := send("DELETE", "/status/418", "", nil); code != 418 {
+		t.Errorf("Expected 418, but got %d", code)
+	}
+
+	// Auth
+	if code, data := send("POST", "/auth/token", "", nil); code != 200 || string(data) != `{"token":"secret"}` {
+		t.Errorf("Expected the token, but got %d %s", code, data)
+	}
+	if code, _ := send("GET", "/auth/protected", "", http.Header{"Authorization": {"Bearer wrong"}}); code != 401 {
+		t.Errorf("Expected 401 for a wrong token, but got %d", code)
+	}
+	if code, _ := send("GET", "/auth/protected", "", http.Header{"Authorization": {"Bearer secret"}}); code != 200 {
+		t.Errorf("Expected 200 for the token, but got %d", code)
+	}
+
+	// Large payloads
+	code, data = send("GET", "/bytes/1048576", "", nil)
+	if code != 200 || len(data) != 1048576 || !json.Valid(data) {
+		t.Errorf("Expected a JSON payload of 1048576 bytes, but got %d bytes", len(data))
+	}
+}
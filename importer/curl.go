@@ -0,0 +1,436 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// curlShortArgs are the short curl options that take an argument
+const curlShortArgs = "AbCcdEeFHKmorTuUwXxYyz"
+
+// curlLongArgs are the long curl options that take an argument and are ignored by the import
+var curlLongArgs = map[string]bool{
+	"--output": true, "--max-time": true, "--connect-timeout": true, "--write-out": true, "--cookie-jar": true,
+	"--retry": true, "--retry-delay": true, "--retry-max-time": true, "--proxy": true, "--range": true,
+	"--limit-rate": true, "--resolve": true, "--connect-to": true, "--config": true, "--max-redirs": true,
+	"--interface": true, "--dns-servers": true, "--continue-at": true, "--proxy-user": true, "--time-cond": true,
+	"--form": true, "--form-string": true, "--upload-file": true, "--cert-type": true, "--key-type": true,
+	"--pass": true, "--ciphers": true, "--capath": true, "--trace": true, "--trace-ascii": true, "--stderr": true,
+}
+
+// curlShortNames maps the short curl options handled by the import to their long names
+var curlShortNames = map[byte]string{
+	'X': "--request", 'H': "--header", 'd': "--data", 'u': "--user", 'b': "--cookie", 'A': "--user-agent",
+	'e': "--referer", 'I': "--head", 'G': "--get", 'k': "--insecure", 'E': "--cert", 'F': "--form",
+	'T': "--upload-file", 'x': "--proxy", 'o': "--output", 'm': "--max-time", 'w': "--write-out",
+	'c': "--cookie-jar", 'K': "--config", 'r': "--range", 'C': "--continue-at", 'U': "--proxy-user",
+	'z': "--time-cond",
+}
+
+// Curl converts a curl command line, as copied from a shell or a browser, into a request
+func Curl(command string) (*Imported, []string, error) {
+	words, err := splitWords(command)
+	if err != nil {
+		return nil, nil, err
+	}
+	return CurlArgs(words)
+}
+
+// CurlArgs converts the arguments of a curl command into a request. The leading curl word is optional. The success
+// code of the request is 200 since curl doesn't know it
+func CurlArgs(args []string) (*Imported, []string, error) {
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+	// Combined short options are split up in place
+	args = append([]string{}, args...)
+	var (
+		warnings                   []string
+		method, rawURL, cookies    string
+		data                       []string
+		get, head, defaultFormType bool
+	)
+	req := &driver.Request{SuccessCode: 200}
+	tls := func() *driver.TLSConfig {
+		if req.TLS == nil {
+			req.TLS = &driver.TLSConfig{}
+		}
+		return req.TLS
+	}
+
+	endOfOptions := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" && !endOfOptions {
+			endOfOptions = true
+			continue
+		}
+		if endOfOptions || !strings.HasPrefix(arg, "-") || arg == "-" {
+			if rawURL != "" {
+				warnings = append(warnings, fmt.Sprintf("only the first URL is imported, %s was ignored", arg))
+				continue
+			}
+			rawURL = arg
+			continue
+		}
+		// Short options may be combined like -sSL or carry their argument like -XPOST, split them up
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			split := make([]string, 0, len(arg))
+			for j := 1; j < len(arg); j++ {
+				split = append(split, "-"+string(arg[j]))
+				if strings.IndexByte(curlShortArgs, arg[j]) >= 0 {
+					if j+1 < len(arg) {
+						split = append(split, arg[j+1:])
+					}
+					break
+				}
+			}
+			args = append(args[:i], append(split, args[i+1:]...)...)
+			i--
+			continue
+		}
+
+		name, value := arg, ""
+		if long, ok := curlShortNames[arg[1]]; ok && len(arg) == 2 {
+			name = long
+		}
+		// Read the argument of the option
+		takesArg := curlLongArgs[name] || (len(name) == 2 && strings.IndexByte(curlShortArgs, name[1]) >= 0)
+		switch name {
+		case "--request", "--header", "--data", "--data-raw", "--data-binary", "--data-ascii", "--data-urlencode",
+			"--json", "--user", "--cookie", "--user-agent", "--referer", "--url", "--cert", "--key", "--cacert",
+			"--oauth2-bearer":
+			takesArg = true
+		}
+		if takesArg {
+			if i+1 >= len(args) {
+				return nil, warnings, fmt.Errorf("option %s needs an argument", arg)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--request":
+			method = strings.ToUpper(value)
+		case "--url":
+			rawURL = value
+		case "--header":
+			parts := strings.SplitN(value, ":", 2)
+			header := strings.TrimSpace(parts[0])
+			if len(parts) < 2 {
+				// "Name;" sends an empty header
+				if strings.HasSuffix(header, ";") {
+					setHeader(req, strings.TrimSuffix(header, ";"), "")
+				}
+				continue
+			}
+			headerValue := strings.TrimSpace(parts[1])
+			switch {
+			case headerValue == "":
+				// "Name:" removes a header curl would send
+			case strings.EqualFold(header, "Content-Type"):
+				req.ContentType = headerValue
+			case strings.EqualFold(header, "Cookie"):
+				cookies = joinCookies(cookies, headerValue)
+			case strings.EqualFold(header, "Authorization"):
+				warnings = append(warnings, authWarning(req, headerValue))
+				if req.Auth == nil {
+					setHeader(req, header, headerValue)
+				}
+			default:
+				setHeader(req, header, headerValue)
+			}
+		case "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json":
+			body, err := curlData(name, value)
+			if err != nil {
+				return nil, warnings, err
+			}
+			data = append(data, body)
+			if name == "--json" {
+				req.ContentType = "application/json"
+				setHeader(req, "Accept", "application/json")
+			} else {
+				defaultFormType = true
+			}
+		case "--user":
+			req.Auth = &driver.AuthConfig{Type: "basic"}
+			warnings = append(warnings, "basic auth was imported as auth type basic, add the user and pass to "+
+				"the credentials file")
+		case "--oauth2-bearer":
+			req.Auth = &driver.AuthConfig{Type: "bearer"}
+			warnings = append(warnings, "the bearer token was imported as auth type bearer, add the token to "+
+				"the credentials file")
+		case "--cookie":
+			if !strings.Contains(value, "=") {
+				warnings = append(warnings, fmt.Sprintf("cookie file %s was ignored", value))
+				continue
+			}
+			cookies = joinCookies(cookies, value)
+		case "--user-agent":
+			setHeader(req, "User-Agent", value)
+		case "--referer":
+			setHeader(req, "Referer", value)
+		case "--head":
+			head = true
+		case "--get":
+			get = true
+		case "--insecure":
+			tls().InsecureSkipVerify = true
+			warnings = append(warnings, "server certificates won't be verified since --insecure was used")
+		case "--cacert":
+			tls().CAFile = value
+		case "--cert":
+			tls().CertFile = value
+		case "--key":
+			tls().KeyFile = value
+		case "--form", "--form-string", "--upload-file":
+			warnings = append(warnings, fmt.Sprintf("%s isn't supported and was ignored", name))
+		case "--proxy":
+			warnings = append(warnings, "the proxy was ignored, use the --proxy flag when running requests")
+		default:
+			if !takesArg && !knownCurlFlag(name) {
+				warnings = append(warnings, fmt.Sprintf("unknown option %s was ignored", arg))
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return nil, warnings, errors.New("no URL found in the curl command")
+	}
+	// curl assumes http when the scheme is missing
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	if u, err := url.Parse(rawURL); err == nil && u.User != nil {
+		req.Auth = &driver.AuthConfig{Type: "basic"}
+		warnings = append(warnings, "the user in the URL was imported as auth type basic, add the user and pass "+
+			"to the credentials file")
+	}
+
+	body := strings.Join(data, "&")
+	if get && body != "" {
+		// -G appends the data to the query
+		if strings.Contains(rawURL, "?") {
+			rawURL += "&" + body
+		} else {
+			rawURL += "?" + body
+		}
+		body = ""
+	}
+	base, endpoint, err := splitURL(rawURL)
+	if err != nil {
+		return nil, warnings, err
+	}
+	req.Base, req.Endpoint = base, endpoint
+
+	switch {
+	case method != "":
+		req.Method = method
+	case head:
+		req.Method = "HEAD"
+	case body != "":
+		req.Method = "POST"
+	default:
+		req.Method = "GET"
+	}
+	if body != "" && req.ContentType == "" && defaultFormType {
+		req.ContentType = "application/x-www-form-urlencoded"
+	}
+	if cookies != "" {
+		setHeader(req, "Cookie", cookies)
+	}
+	return &Imported{Request: req, Body: []byte(body)}, warnings, nil
+}
+
+// knownCurlFlag reports whether a curl option without an argument is safe to ignore
+func knownCurlFlag(name string) bool {
+	switch name {
+	case "--silent", "--show-error", "--location", "--include", "--verbose", "--compressed", "--globoff",
+		"--http1.1", "--http2", "--fail", "--no-buffer", "--progress-bar", "--location-trusted", "--ipv4",
+		"--ipv6", "--raw", "--tlsv1.2", "--tlsv1.3", "-s", "-S", "-L", "-i", "-v", "-f", "-N", "-g", "-#":
+		return true
+	}
+	return false
+}
+
+// curlData returns the data of a data option, reading it from a file for @file arguments like curl does
+func curlData(option, value string) (string, error) {
+	readFile := func(path string) (string, error) {
+		if path == "-" {
+			return "", fmt.Errorf("%s @- reads from stdin and can't be imported", option)
+		}
+		contents, err := ioutil.ReadFile(path)
+		return string(contents), err
+	}
+
+	switch option {
+	case "--data-raw":
+		return value, nil
+	case "--data-urlencode":
+		// content, =content, name=content, @file and name@file
+		if eq := strings.Index(value, "="); eq >= 0 {
+			if eq == 0 {
+				return url.QueryEscape(value[1:]), nil
+			}
+			return value[:eq+1] + url.QueryEscape(value[eq+1:]), nil
+		}
+		if at := strings.Index(value, "@"); at >= 0 {
+			contents, err := readFile(value[at+1:])
+			if err != nil {
+				return "", err
+			}
+			if at == 0 {
+				return url.QueryEscape(contents), nil
+			}
+			return value[:at+1] + url.QueryEscape(contents), nil
+		}
+		return url.QueryEscape(value), nil
+	}
+
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	contents, err := readFile(value[1:])
+	if err != nil {
+		return "", err
+	}
+	// -d strips carriage returns and newlines from files, --data-binary and --json keep them
+	if option == "--data" || option == "--data-ascii" {
+		contents = strings.NewReplacer("\r", "", "\n", "").Replace(contents)
+	}
+	return contents, nil
+}
+
+// joinCookies joins cookie strings into a single Cookie header value
+func joinCookies(cookies, more string) string {
+	if cookies == "" {
+		return more
+	}
+	return cookies + "; " + more
+}
+
+// authWarning converts a Bearer or Basic Authorization header into the matching auth type, so that the secret
+// isn't written to the requests file, and returns a warning about it
+func authWarning(req *driver.Request, value string) string {
+	scheme := strings.ToLower(strings.SplitN(value, " ", 2)[0])
+	switch scheme {
+	case "bearer":
+		req.Auth = &driver.AuthConfig{Type: "bearer"}
+		return "the bearer token was imported as auth type bearer, add the token to the credentials file"
+	case "basic":
+		req.Auth = &driver.AuthConfig{Type: "basic"}
+		return "basic auth was imported as auth type basic, add the user and pass to the credentials file"
+	}
+	return "the Authorization header was imported as is, consider moving its secret to the credentials file"
+}
+
+// splitWords splits a command line into words following the quoting rules of POSIX shells, including the $'...'
+// quotes used by browsers when copying requests as curl commands
+func splitWords(command string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		escapes = map[byte]string{'n': "\n", 't': "\t", 'r': "\r", '\\': "\\", '\'': "'", '"': "\"", '0': "\x00"}
+	)
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\':
+			// Line continuations are dropped, other characters are escaped
+			if i+1 < len(command) {
+				i++
+				if command[i] != '\n' && command[i] != '\r' {
+					word.WriteByte(command[i])
+					inWord = true
+				} else if command[i] == '\r' && i+1 < len(command) && command[i+1] == '\n' {
+					i++
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(command) && command[i+1] == '\'':
+			i += 2
+			for ; i < len(command) && command[i] != '\''; i++ {
+				if command[i] != '\\' || i+1 >= len(command) {
+					word.WriteByte(command[i])
+					continue
+				}
+				i++
+				if s, ok := escapes[command[i]]; ok {
+					word.WriteString(s)
+					continue
+				}
+				// \xHH and \uHHHH escapes
+				size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[command[i]]
+				if size > 0 && i+size < len(command) {
+					if r, err := strconv.ParseUint(command[i+1:i+1+size], 16, 32); err == nil {
+						if command[i] == 'x' {
+							word.WriteByte(byte(r))
+						} else {
+							word.WriteRune(rune(r))
+						}
+						i += size
+						continue
+					}
+				}
+				word.WriteByte('\\')
+				word.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, errors.New("unterminated $' quote")
+			}
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("$`\"\\\n", command[i+1]) >= 0 {
+					i++
+					if command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if i >= len(command) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	for _, w := range words {
+		if !utf8.ValidString(w) {
+			return nil, fmt.Errorf("%q isn't valid UTF-8", w)
+		}
+	}
+	return words, nil
+}
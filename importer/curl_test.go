@@ -0,0 +1,92 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"github.com/fercevik729/Wave/driver"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	command := "curl 'https://api.example.com/a b' \\\n  -H \"X-Quote: \\\"q\\\"\" --data-raw $'{\"a\":\"\\u00e9\\n\"}' -d x\\ y"
+	expected := []string{"curl", "https://api.example.com/a b", "-H", `X-Quote: "q"`, "--data-raw",
+		"{\"a\":\"é\n\"}", "-d", "x y"}
+	actual, err := splitWords(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %q, but got %q", expected, actual)
+	}
+	if _, err := splitWords("curl 'unterminated"); err == nil {
+		t.Errorf("Expected an error for an unterminated quote")
+	}
+}
+
+func TestCurl(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	if err := ioutil.WriteFile(bodyFile, []byte("{\"name\":\n\"wave\"}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command  string
+		expected *driver.Request
+		body     string
+		warnings int
+	}{
+		{
+			command: `curl https://api.example.com/users?page=2`,
+			expected: &driver.Request{Method: "GET", Base: "https://api.example.com", Endpoint: "/users?page=2",
+				SuccessCode: 200},
+		},
+		{
+			command: `curl -sSL -XPUT 'https://api.example.com/users/1' -H 'Content-Type: application/json' ` +
+				`-H 'Accept: application/json' --data-binary @` + bodyFile,
+			expected: &driver.Request{Method: "PUT", Base: "https://api.example.com", Endpoint: "/users/1",
+				SuccessCode: 200, ContentType: "application/json", Headers: map[string]string{"Accept": "application/json"}},
+			body: "{\"name\":\n\"wave\"}\n",
+		},
+		{
+			command: `curl api.example.com/login -d @` + bodyFile + ` -d remember=1 -u developer:secret ` +
+				`-b 'session=abc' --cookie 'theme=dark'`,
+			expected: &driver.Request{Method: "POST", Base: "http://api.example.com", Endpoint: "/login",
+				SuccessCode: 200, ContentType: "application/x-www-form-urlencoded",
+				Auth: &driver.AuthConfig{Type: "basic"}, Headers: map[string]string{"Cookie": "session=abc; theme=dark"}},
+			body:     "{\"name\":\"wave\"}&remember=1",
+			warnings: 1,
+		},
+		{
+			command: `curl -G https://api.example.com/search --data-urlencode 'q=a b' -H 'Authorization: Bearer xyz' -k`,
+			expected: &driver.Request{Method: "GET", Base: "https://api.example.com", Endpoint: "/search?q=a+b",
+				SuccessCode: 200, Auth: &driver.AuthConfig{Type: "bearer"},
+				TLS: &driver.TLSConfig{InsecureSkipVerify: true}},
+			warnings: 2,
+		},
+	}
+	for _, test := range tests {
+		imported, warnings, err := Curl(test.command)
+		if err != nil {
+			t.Errorf("%s: %v", test.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(imported.Request, test.expected) {
+			t.Errorf("%s: expected %+v, but got %+v", test.command, test.expected, imported.Request)
+		}
+		if string(imported.Body) != test.body {
+			t.Errorf("%s: expected the body %q, but got %q", test.command, test.body, imported.Body)
+		}
+		if len(warnings) != test.warnings {
+			t.Errorf("%s: expected %d warnings, but got %q", test.command, test.warnings, warnings)
+		}
+	}
+
+	if _, _, err := Curl("curl -H 'Accept: */*'"); err == nil {
+		t.Errorf("Expected an error for a command without a URL")
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HAROptions filters the entries of a HAR file:
+// Domains: only entries sent to these domains or their subdomains are imported, every entry is imported if it's empty
+// ContentTypes: only entries whose response content type contains one of these, such as json, are imported
+// ThinkTime: sets the think time of every request to the time between the previous response and the request
+type HAROptions struct {
+	Domains      []string
+	ContentTypes []string
+	ThinkTime    bool
+}
+
+// harFile is the subset of the HTTP Archive format read by the import
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         struct {
+		Method   string    `json:"method"`
+		URL      string    `json:"url"`
+		Headers  []harPair `json:"headers"`
+		PostData *struct {
+			MimeType string    `json:"mimeType"`
+			Text     string    `json:"text"`
+			Params   []harPair `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harSkippedHeaders are the headers set by the HTTP client or the browser that aren't imported
+var harSkippedHeaders = map[string]bool{
+	"host": true, "content-length": true, "connection": true, "accept-encoding": true, "keep-alive": true,
+	"transfer-encoding": true, "upgrade": true, "te": true, "trailer": true,
+}
+
+// HAR converts the entries of an HTTP Archive, as exported by the devtools of a browser, into requests in the order
+// they were sent. The observed status of every entry becomes the success code of its request
+func HAR(data []byte, opts HAROptions) ([]*Imported, []string, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, nil, fmt.Errorf("couldn't parse the HAR file: %v", err)
+	}
+	entries := har.Log.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	var (
+		imported   []*Imported
+		warnings   []string
+		previous   *harEntry
+		hasCookies bool
+	)
+	for i := range entries {
+		entry := &entries[i]
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if !matchesDomain(u.Host, opts.Domains) || !matchesContentType(entry.Response.Content.MimeType,
+			opts.ContentTypes) {
+			continue
+		}
+		// Requests blocked by the browser or that never got a response
+		if entry.Response.Status == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s %s got no response and was skipped", entry.Request.Method,
+				entry.Request.URL))
+			continue
+		}
+
+		base, endpoint, err := splitURL(entry.Request.URL)
+		if err != nil {
+			return nil, warnings, err
+		}
+		req := &driver.Request{
+			Method:      strings.ToUpper(entry.Request.Method),
+			Base:        base,
+			Endpoint:    endpoint,
+			SuccessCode: entry.Response.Status,
+		}
+		for _, header := range entry.Request.Headers {
+			name := strings.ToLower(header.Name)
+			switch {
+			case strings.HasPrefix(name, ":") || harSkippedHeaders[name]:
+				// HTTP/2 pseudo headers and headers set by the client
+			case name == "content-type":
+				req.ContentType = header.Value
+			case name == "authorization":
+				warning := authWarning(req, header.Value)
+				if req.Auth == nil {
+					setHeader(req, header.Name, header.Value)
+				}
+				warnings = appendOnce(warnings, warning)
+			default:
+				if name == "cookie" {
+					hasCookies = true
+				}
+				setHeader(req, header.Name, header.Value)
+			}
+		}
+
+		var body []byte
+		if post := entry.Request.PostData; post != nil {
+			body = []byte(post.Text)
+			// Form posts may only list their params
+			if post.Text == "" && len(post.Params) > 0 {
+				form := url.Values{}
+				for _, param := range post.Params {
+					form.Add(param.Name, param.Value)
+				}
+				body = []byte(form.Encode())
+			}
+			if req.ContentType == "" {
+				req.ContentType = post.MimeType
+			}
+		}
+
+		// The think time is the pause between the previous response and this request
+		if opts.ThinkTime && previous != nil {
+			end := previous.StartedDateTime.Add(time.Duration(previous.Time * float64(time.Millisecond)))
+			if think := entry.StartedDateTime.Sub(end).Round(time.Millisecond); think > 0 {
+				req.ThinkTime = think.String()
+			}
+		}
+		previous = entry
+		imported = append(imported, &Imported{Request: req, Body: body})
+	}
+
+	if hasCookies {
+		warnings = append(warnings, "session cookies were imported as Cookie headers and may expire")
+	}
+	return imported, warnings, nil
+}
+
+// matchesDomain reports whether the host is one of the domains or one of their subdomains
+func matchesDomain(host string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesContentType reports whether the content type contains one of the filters
+func matchesContentType(contentType string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	contentType = strings.ToLower(contentType)
+	for _, filter := range filters {
+		if strings.Contains(contentType, strings.ToLower(filter)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"github.com/fercevik729/Wave/driver"
+	"reflect"
+	"testing"
+)
+
+// session is a HAR file recorded out of order with a page, an API login and an API call
+const session = `{"log": {"entries": [
+	{
+		"startedDateTime": "2022-06-01T10:00:02.000Z", "time": 50,
+		"request": {"method": "GET", "url": "https://api.example.com/users?page=1", "headers": [
+			{"name": ":authority", "value": "api.example.com"},
+			{"name": "Accept-Encoding", "value": "gzip"},
+			{"name": "Authorization", "value": "Bearer xyz"},
+			{"name": "Cookie", "value": "session=abc"}
+		]},
+		"response": {"status": 200, "content": {"mimeType": "application/json; charset=utf-8"}}
+	},
+	{
+		"startedDateTime": "2022-06-01T10:00:00.000Z", "time": 100,
+		"request": {"method": "GET", "url": "https://www.example.com/", "headers": []},
+		"response": {"status": 200, "content": {"mimeType": "text/html"}}
+	},
+	{
+		"startedDateTime": "2022-06-01T10:00:01.000Z", "time": 500,
+		"request": {"method": "POST", "url": "https://api.example.com/login", "headers": [],
+			"postData": {"mimeType": "application/x-www-form-urlencoded", "params": [
+				{"name": "user", "value": "developer"}
+			]}},
+		"response": {"status": 201, "content": {"mimeType": "application/json"}}
+	},
+	{
+		"startedDateTime": "2022-06-01T10:00:03.000Z", "time": 0,
+		"request": {"method": "GET", "url": "https://ads.tracker.com/pixel", "headers": []},
+		"response": {"status": 0, "content": {"mimeType": ""}}
+	}
+]}}`
+
+func TestHAR(t *testing.T) {
+	imported, warnings, err := HAR([]byte(session), HAROptions{
+		Domains:      []string{"example.com"},
+		ContentTypes: []string{"json"},
+		ThinkTime:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*driver.Request{{
+		Method:      "POST",
+		Base:        "https://api.example.com",
+		Endpoint:    "/login",
+		SuccessCode: 201,
+		ContentType: "application/x-www-form-urlencoded",
+	}, {
+		Method:      "GET",
+		Base:        "https://api.example.com",
+		Endpoint:    "/users?page=1",
+		SuccessCode: 200,
+		Auth:        &driver.AuthConfig{Type: "bearer"},
+		Headers:     map[string]string{"Cookie": "session=abc"},
+		ThinkTime:   "500ms",
+	}}
+	if len(imported) != len(expected) {
+		t.Fatalf("Expected %d requests, but got %d", len(expected), len(imported))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(imported[i].Request, expected[i]) {
+			t.Errorf("Expected %+v, but got %+v", expected[i], imported[i].Request)
+		}
+	}
+	if string(imported[0].Body) != "user=developer" {
+		t.Errorf("Expected the form params as the body, but got %s", imported[0].Body)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Expected warnings about the bearer token and cookies, but got %q", warnings)
+	}
+
+	// Without filters every HTTP entry with a response is imported
+	imported, warnings, err = HAR([]byte(session), HAROptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 3 || imported[0].Request.Base != "https://www.example.com" || imported[1].Request.ThinkTime != "" {
+		t.Errorf("Expected 3 requests in order without think times, but got %d", len(imported))
+	}
+	if len(warnings) != 3 {
+		t.Errorf("Expected a warning about the entry without a response, but got %q", warnings)
+	}
+}
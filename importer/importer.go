@@ -0,0 +1,195 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Imported is a request converted from another tool:
+// Name: suggested name of the request in the requests YAML file, a request-N name is used if it's empty or taken
+// Request: the request in the shape read by driver.New
+// Body: body of the request, written to a data file when the request is appended
+// Schema: JSON schema of the response body, written to a schema file when the request is appended
+// Expect: expected JSON response body, written to an expect file when the request is appended
+type Imported struct {
+	Name    string
+	Request *driver.Request
+	Body    []byte
+	Schema  []byte
+	Expect  []byte
+}
+
+// nonName matches the characters replaced when turning a suggested name into a request name
+var nonName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Append writes the bodies, schemas and expected bodies of the imported requests to files in the data directory and
+// appends the requests to the requests YAML file, keeping its existing contents. It returns the names the requests
+// were added under
+func Append(reqFile, dataDir string, imported []*Imported) ([]string, error) {
+	existing, err := ioutil.ReadFile(reqFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var entries yaml.MapSlice
+	if err := yaml.Unmarshal(existing, &entries); err != nil {
+		return nil, fmt.Errorf("couldn't parse %s: %v", reqFile, err)
+	}
+	taken := make(map[string]bool)
+	for _, entry := range entries {
+		taken[fmt.Sprint(entry.Key)] = true
+	}
+
+	names := make([]string, 0, len(imported))
+	var out []byte
+	next := 1
+	for _, imp := range imported {
+		// Prefer the suggested name, falling back to the next free request-N name
+		name := strings.Trim(nonName.ReplaceAllString(strings.ToLower(imp.Name), "-"), "-")
+		for name == "" || taken[name] {
+			name = "request-" + strconv.Itoa(next)
+			next++
+		}
+		taken[name] = true
+
+		if len(imp.Body) > 0 {
+			dataFile, err := writeDataFile(dataDir, name, imp.Body, "")
+			if err != nil {
+				return nil, err
+			}
+			imp.Request.DataFile = dataFile
+		}
+		if len(imp.Schema) > 0 {
+			schemaFile, err := writeDataFile(dataDir, name, imp.Schema, ".schema.json")
+			if err != nil {
+				return nil, err
+			}
+			imp.Request.SchemaFile = schemaFile
+		}
+		if len(imp.Expect) > 0 {
+			expectFile, err := writeDataFile(dataDir, name, imp.Expect, ".expect.json")
+			if err != nil {
+				return nil, err
+			}
+			imp.Request.ExpectFile = expectFile
+		}
+		names = append(names, name)
+
+		// Separate the requests with a blank line like the example requests files
+		entry, err := yaml.Marshal(yaml.MapSlice{{Key: name, Value: imp.Request}})
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > 0 {
+			out = append(out, '\n')
+		}
+		out = append(out, entry...)
+	}
+
+	if len(bytes.TrimSpace(existing)) > 0 {
+		out = append([]byte("\n"), out...)
+		if !bytes.HasSuffix(existing, []byte("\n")) {
+			out = append([]byte("\n"), out...)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(reqFile), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(reqFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(out); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return names, f.Close()
+}
+
+// writeDataFile writes the contents to a new file in the data directory named after the request and returns its
+// path. Without an extension, contents that are valid JSON get a .json extension
+func writeDataFile(dataDir, name string, body []byte, ext string) (string, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", err
+	}
+	if ext == "" {
+		ext = ".txt"
+		if json.Valid(body) {
+			ext = ".json"
+		}
+	}
+	// Never overwrite an existing data file
+	path := filepath.Join(dataDir, name+ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		path = filepath.Join(dataDir, name+"-"+strconv.Itoa(i)+ext)
+	}
+	if err := ioutil.WriteFile(path, body, 0644); err != nil {
+		return "", err
+	}
+	// Keep the ./ prefix of relative data directories like the example requests files
+	path = filepath.ToSlash(path)
+	if strings.HasPrefix(filepath.ToSlash(dataDir), "./") {
+		path = "./" + path
+	}
+	return path, nil
+}
+
+// splitURL splits a URL into the base and endpoint fields of a request, dropping any user info and fragment. The
+// endpoint is kept as written so that {id} placeholders aren't escaped
+func splitURL(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", "", fmt.Errorf("%q isn't an absolute URL", rawURL)
+	}
+	endpoint := rawURL[len(u.Scheme)+len("://"):]
+	if i := strings.IndexAny(endpoint, "/?#"); i >= 0 {
+		endpoint = endpoint[i:]
+	} else {
+		endpoint = ""
+	}
+	if i := strings.IndexByte(endpoint, '#'); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+	return u.Scheme + "://" + u.Host, endpoint, nil
+}
+
+// setHeader sets a header of the request
+func setHeader(req *driver.Request, name, value string) {
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+	req.Headers[name] = value
+}
+
+// appendOnce appends a warning unless it was already given
+func appendOnce(warnings []string, warning string) []string {
+	for _, w := range warnings {
+		if w == warning {
+			return warnings
+		}
+	}
+	return append(warnings, warning)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"github.com/fercevik729/Wave/driver"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	reqFile := filepath.Join(dir, "reqs.yaml")
+	credFile := filepath.Join(dir, "cred.yaml")
+	dataDir := filepath.Join(dir, "data")
+	existing := "request-1:\n  method: \"GET\"\n  base: \"https://api.example.com\"\n  endpoint: \"/users\"\n" +
+		"  success-code: 200\n"
+	if err := ioutil.WriteFile(reqFile, []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(credFile, []byte("user: \"developer\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := []*Imported{
+		{
+			Request: &driver.Request{Method: "POST", Base: "https://api.example.com", Endpoint: "/users",
+				SuccessCode: 201, ContentType: "application/json", Headers: map[string]string{"X-Trace": "1"}},
+			Body: []byte(`{"name":"wave"}`),
+		},
+		{
+			Name:    "Delete User",
+			Request: &driver.Request{Method: "DELETE", Base: "https://api.example.com", Endpoint: "/users/1"},
+		},
+	}
+	names, err := Append(reqFile, dataDir, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"request-2", "delete-user"}) {
+		t.Errorf("Expected the next free names, but got %v", names)
+	}
+
+	// The appended requests are read back by the driver
+	reqs, _ := driver.New(reqFile, credFile)
+	if len(reqs) != 3 {
+		t.Fatalf("Expected 3 requests, but got %d", len(reqs))
+	}
+	if reqs[1].Method != "POST" || reqs[1].SuccessCode != 201 || reqs[1].Headers["X-Trace"] != "1" {
+		t.Errorf("Expected the imported POST request, but got %+v", reqs[1])
+	}
+	expectedFile := filepath.ToSlash(filepath.Join(dataDir, "request-2.json"))
+	if reqs[1].DataFile != expectedFile {
+		t.Errorf("Expected the data file %s, but got %s", expectedFile, reqs[1].DataFile)
+	}
+	body, _ := ioutil.ReadFile(expectedFile)
+	if string(body) != `{"name":"wave"}` {
+		t.Errorf("Expected the body to be written to the data file, but got %s", body)
+	}
+	if reqs[2].Method != "DELETE" || reqs[2].DataFile != "" {
+		t.Errorf("Expected the imported DELETE request, but got %+v", reqs[2])
+	}
+}
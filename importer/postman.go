@@ -0,0 +1,483 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"mime/multipart"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// postmanBoundary is the boundary of the multipart bodies converted from form data, it has to be fixed since the body
+// is written to a data file along with the content type
+const postmanBoundary = "WaveFormBoundary7MA4YWxkTrZu0gW"
+
+// postmanVariable matches the {{variables}} of a collection
+var postmanVariable = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`)
+
+// postmanSecretName matches the names of variables holding secrets, along with the variables of the secret type
+var postmanSecretName = regexp.MustCompile(`(?i)token|secret|passw|pwd|api[-_]?key|credential`)
+
+// postmanCollection is the subset of the Postman v2.1 collection format read by the import
+type postmanCollection struct {
+	Info struct {
+		Schema string `json:"schema"`
+	} `json:"info"`
+	postmanItem
+	Variable []postmanPair `json:"variable"`
+}
+
+// postmanItem is a folder with items or a request
+type postmanItem struct {
+	Name     string          `json:"name"`
+	Item     []postmanItem   `json:"item"`
+	Request  *postmanRequest `json:"request"`
+	Response []struct {
+		Code int `json:"code"`
+	} `json:"response"`
+	Auth  *postmanAuth `json:"auth"`
+	Event []struct {
+		Listen string `json:"listen"`
+		Script struct {
+			Exec json.RawMessage `json:"exec"`
+		} `json:"script"`
+	} `json:"event"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	Header []postmanPair   `json:"header"`
+	URL    json.RawMessage `json:"url"`
+	Auth   *postmanAuth    `json:"auth"`
+	Body   *postmanBody    `json:"body"`
+}
+
+type postmanBody struct {
+	Mode       string        `json:"mode"`
+	Raw        string        `json:"raw"`
+	URLEncoded []postmanPair `json:"urlencoded"`
+	FormData   []postmanPair `json:"formdata"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+type postmanAuth struct {
+	Type   string                   `json:"type"`
+	Params map[string][]postmanPair `json:"-"`
+}
+
+// postmanPair is a key value pair of headers, variables, form fields and auth settings
+type postmanPair struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type"`
+	Disabled bool        `json:"disabled"`
+	Enabled  *bool       `json:"enabled"`
+}
+
+// postmanEnvironment is an exported Postman environment
+type postmanEnvironment struct {
+	Values []postmanPair `json:"values"`
+}
+
+// UnmarshalJSON reads the type of the auth and the settings listed under it
+func (a *postmanAuth) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw["type"], &a.Type); err != nil {
+		return err
+	}
+	a.Params = make(map[string][]postmanPair)
+	for key, value := range raw {
+		var params []postmanPair
+		if key != "type" && json.Unmarshal(value, &params) == nil {
+			a.Params[key] = params
+		}
+	}
+	return nil
+}
+
+// param returns the value of a setting of the auth
+func (a *postmanAuth) param(key string) string {
+	for _, p := range a.Params[a.Type] {
+		if p.Key == key {
+			return p.value()
+		}
+	}
+	return ""
+}
+
+// value returns the value of the pair as a string
+func (p postmanPair) value() string {
+	if p.Value == nil {
+		return ""
+	}
+	if s, ok := p.Value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(p.Value)
+}
+
+// active reports whether the pair isn't disabled
+func (p postmanPair) active() bool {
+	return !p.Disabled && (p.Enabled == nil || *p.Enabled)
+}
+
+// postmanImport holds the state of a collection import
+type postmanImport struct {
+	variables  map[string]string
+	secrets    map[string]bool
+	imported   []*Imported
+	warnings   []string
+	unresolved map[string]bool
+	withheld   map[string]bool
+}
+
+// Postman converts the requests of a Postman v2.1 collection into requests, walking its folders in order. The
+// {{variables}} of the collection are replaced with their values, the values of the environment taking precedence
+// if one is given. Secrets of the auth settings are left to the credentials file and secret variables are left as is
+func Postman(collection []byte, environment []byte) ([]*Imported, []string, error) {
+	var c postmanCollection
+	if err := json.Unmarshal(collection, &c); err != nil {
+		return nil, nil, fmt.Errorf("couldn't parse the Postman collection: %v", err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.") {
+		return nil, nil, fmt.Errorf("unsupported collection schema %s, export the collection as v2.1", c.Info.Schema)
+	}
+
+	p := &postmanImport{variables: make(map[string]string), secrets: make(map[string]bool),
+		unresolved: make(map[string]bool), withheld: make(map[string]bool)}
+	for _, v := range c.Variable {
+		if v.active() {
+			p.setVariable(v)
+		}
+	}
+	if len(environment) > 0 {
+		var env postmanEnvironment
+		if err := json.Unmarshal(environment, &env); err != nil {
+			return nil, nil, fmt.Errorf("couldn't parse the Postman environment: %v", err)
+		}
+		for _, v := range env.Values {
+			if v.active() {
+				p.setVariable(v)
+			}
+		}
+	}
+
+	if err := p.walk(&c.postmanItem, nil, c.Auth); err != nil {
+		return nil, p.warnings, err
+	}
+	if len(p.unresolved) > 0 {
+		p.warnings = append(p.warnings, fmt.Sprintf("variables without a value were left as is: %s",
+			sortedNames(p.unresolved)))
+	}
+	if len(p.withheld) > 0 {
+		p.warnings = append(p.warnings, fmt.Sprintf("secret variables weren't written to the requests file and "+
+			"were left as is, move them to the auth of the requests and the credentials file: %s",
+			sortedNames(p.withheld)))
+	}
+	return p.imported, p.warnings, nil
+}
+
+// setVariable sets the value of a variable, marking it as a secret if it's of the secret type or named like one
+func (p *postmanImport) setVariable(v postmanPair) {
+	p.variables[v.Key] = v.value()
+	p.secrets[v.Key] = v.Type == "secret" || postmanSecretName.MatchString(v.Key)
+}
+
+// sortedNames returns the sorted names of the set separated by commas
+func sortedNames(set map[string]bool) string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// walk converts the requests of an item and its children, which inherit its auth
+func (p *postmanImport) walk(item *postmanItem, path []string, auth *postmanAuth) error {
+	for _, event := range item.Event {
+		if script := strings.TrimSpace(string(event.Script.Exec)); script != "" && script != "[]" &&
+			script != `[""]` {
+			name := strings.Join(path, "/")
+			if name == "" {
+				name = "the collection"
+			}
+			p.warnings = append(p.warnings, fmt.Sprintf("the %s script of %s isn't supported and was ignored",
+				event.Listen, name))
+		}
+	}
+	if item.Auth != nil && item.Auth.Type != "inherit" {
+		auth = item.Auth
+	}
+	if item.Request != nil {
+		if item.Request.Auth != nil && item.Request.Auth.Type != "inherit" {
+			auth = item.Request.Auth
+		}
+		return p.convert(item, path, auth)
+	}
+	for i := range item.Item {
+		child := &item.Item[i]
+		if err := p.walk(child, append(append([]string{}, path...), child.Name), auth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convert converts a Postman request into a request named after its folders
+func (p *postmanImport) convert(item *postmanItem, path []string, auth *postmanAuth) error {
+	r := item.Request
+	name := strings.Join(path, "/")
+	rawURL, err := p.url(r.URL, name)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	base, endpoint, err := splitURL(rawURL)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	req := &driver.Request{
+		Method:      strings.ToUpper(r.Method),
+		Base:        base,
+		Endpoint:    endpoint,
+		SuccessCode: 200,
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	// Saved example responses tell the expected status
+	if len(item.Response) > 0 && item.Response[0].Code != 0 {
+		req.SuccessCode = item.Response[0].Code
+	}
+	for _, header := range r.Header {
+		if !header.active() {
+			continue
+		}
+		value := p.expand(header.value())
+		if strings.EqualFold(header.Key, "Content-Type") {
+			req.ContentType = value
+			continue
+		}
+		if strings.EqualFold(header.Key, "Authorization") {
+			p.warnings = appendOnce(p.warnings, authWarning(req, value))
+			if req.Auth != nil {
+				continue
+			}
+		}
+		setHeader(req, header.Key, value)
+	}
+	p.auth(req, auth, name)
+
+	body, err := p.body(req, r.Body, name)
+	if err != nil {
+		return err
+	}
+	p.imported = append(p.imported, &Imported{Name: name, Request: req, Body: body})
+	return nil
+}
+
+// url returns the URL of a request, which is either a string or an object with the raw URL and path variables
+func (p *postmanImport) url(raw json.RawMessage, name string) (string, error) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return p.expand(s), nil
+	}
+	var u struct {
+		Raw      string        `json:"raw"`
+		Variable []postmanPair `json:"variable"`
+	}
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return "", fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Raw == "" {
+		return "", fmt.Errorf("missing url")
+	}
+	rawURL := p.expand(u.Raw)
+	// Path variables such as :id become the {id} notation of id ranges if they have no value
+	for _, v := range u.Variable {
+		value := p.expand(v.value())
+		if value == "" {
+			value = "{id}"
+			p.warnings = append(p.warnings, fmt.Sprintf("the path variable %s of %s became {id}, set the id-range "+
+				"of the request", v.Key, name))
+		}
+		rawURL = replacePathVariable(rawURL, v.Key, value)
+	}
+	return rawURL, nil
+}
+
+// replacePathVariable replaces the first path segment of the URL that is exactly :name with the value, so that :id
+// doesn't match the start of :idx
+func replacePathVariable(rawURL, name, value string) string {
+	path, query := rawURL, ""
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+		path, query = rawURL[:i], rawURL[i:]
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == ":"+name {
+			segments[i] = value
+			break
+		}
+	}
+	return strings.Join(segments, "/") + query
+}
+
+// auth converts the auth settings of a request
+func (p *postmanImport) auth(req *driver.Request, auth *postmanAuth, name string) {
+	if auth == nil || req.Auth != nil {
+		return
+	}
+	switch auth.Type {
+	case "noauth", "":
+	case "bearer":
+		req.Auth = &driver.AuthConfig{Type: "bearer"}
+		p.warnings = appendOnce(p.warnings, "bearer tokens were imported as auth type bearer, add the token to "+
+			"the credentials file")
+	case "basic":
+		req.Auth = &driver.AuthConfig{Type: "basic"}
+		p.warnings = appendOnce(p.warnings, "basic auth was imported as auth type basic, add the user and pass to "+
+			"the credentials file")
+	case "apikey":
+		req.Auth = &driver.AuthConfig{Type: "api-key", Header: p.expand(auth.param("key"))}
+		if auth.param("in") == "query" {
+			req.Auth.Query, req.Auth.Header = req.Auth.Header, ""
+		}
+		p.warnings = appendOnce(p.warnings, "API keys were imported as auth type api-key, add the api-key to the "+
+			"credentials file")
+	case "awsv4":
+		req.Auth = &driver.AuthConfig{Type: "sigv4", Region: p.expand(auth.param("region")),
+			Service: p.expand(auth.param("service"))}
+		p.warnings = appendOnce(p.warnings, "AWS signatures were imported as auth type sigv4, add the aws keys to "+
+			"the credentials file")
+	case "oauth2":
+		req.Auth = &driver.AuthConfig{Type: "bearer"}
+		p.warnings = appendOnce(p.warnings, "OAuth 2.0 was imported as auth type bearer, add the oauth2 settings "+
+			"to the credentials file")
+	default:
+		p.warnings = append(p.warnings, fmt.Sprintf("%s auth of %s isn't supported and was ignored", auth.Type,
+			name))
+	}
+}
+
+// body converts the body of a request, setting its content type if no header did
+func (p *postmanImport) body(req *driver.Request, b *postmanBody, name string) ([]byte, error) {
+	if b == nil {
+		return nil, nil
+	}
+	contentType := ""
+	var body []byte
+	switch b.Mode {
+	case "raw":
+		body = []byte(p.expand(b.Raw))
+		contentType = map[string]string{
+			"json":       "application/json",
+			"xml":        "application/xml",
+			"html":       "text/html",
+			"javascript": "application/javascript",
+			"text":       "text/plain",
+		}[b.Options.Raw.Language]
+	case "urlencoded":
+		form := url.Values{}
+		for _, field := range b.URLEncoded {
+			if field.active() {
+				form.Add(p.expand(field.Key), p.expand(field.value()))
+			}
+		}
+		body = []byte(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case "formdata":
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		if err := w.SetBoundary(postmanBoundary); err != nil {
+			return nil, err
+		}
+		for _, field := range b.FormData {
+			if !field.active() {
+				continue
+			}
+			if field.Type == "file" {
+				p.warnings = append(p.warnings, fmt.Sprintf("the file field %s of %s isn't supported and was "+
+					"ignored", field.Key, name))
+				continue
+			}
+			if err := w.WriteField(p.expand(field.Key), p.expand(field.value())); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+		contentType = w.FormDataContentType()
+	case "graphql":
+		if b.GraphQL == nil {
+			return nil, nil
+		}
+		query := map[string]interface{}{"query": p.expand(b.GraphQL.Query)}
+		if vars := strings.TrimSpace(p.expand(b.GraphQL.Variables)); vars != "" {
+			query["variables"] = json.RawMessage(vars)
+		}
+		var err error
+		body, err = json.Marshal(query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid GraphQL variables: %v", name, err)
+		}
+		contentType = "application/json"
+	default:
+		p.warnings = append(p.warnings, fmt.Sprintf("the %s body of %s isn't supported and was ignored", b.Mode,
+			name))
+		return nil, nil
+	}
+	if req.ContentType == "" && len(body) > 0 {
+		req.ContentType = contentType
+	}
+	return body, nil
+}
+
+// expand replaces the {{variables}} in the text with their values, variables can refer to other variables. Secret
+// variables are left as is to keep their values out of the requests file
+func (p *postmanImport) expand(text string) string {
+	for i := 0; i < 10 && strings.Contains(text, "{{"); i++ {
+		expanded := postmanVariable.ReplaceAllStringFunc(text, func(match string) string {
+			name := postmanVariable.FindStringSubmatch(match)[1]
+			if p.secrets[name] {
+				p.withheld[name] = true
+				return match
+			}
+			if value, ok := p.variables[name]; ok {
+				return value
+			}
+			p.unresolved[name] = true
+			return match
+		})
+		if expanded == text {
+			break
+		}
+		text = expanded
+	}
+	return text
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"github.com/fercevik729/Wave/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const collection = `{
+	"info": {"name": "Users", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+	"auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+	"variable": [{"key": "baseUrl", "value": "https://api.example.com"}, {"key": "version", "value": "v1"}],
+	"item": [
+		{
+			"name": "Users",
+			"event": [{"listen": "prerequest", "script": {"exec": ["pm.environment.set('x', 1)"]}}],
+			"item": [
+				{
+					"name": "Create user",
+					"request": {
+						"method": "POST",
+						"header": [{"key": "X-Trace", "value": "{{traceId}}"}, {"key": "X-Off", "value": "1", "disabled": true}],
+						"url": {"raw": "{{baseUrl}}/{{version}}/users?notify=true"},
+						"body": {"mode": "raw", "raw": "{\"name\": \"{{userName}}\"}", "options": {"raw": {"language": "json"}}}
+					},
+					"response": [{"code": 201}]
+				},
+				{
+					"name": "Get user",
+					"request": {
+						"method": "GET",
+						"auth": {"type": "apikey", "apikey": [{"key": "key", "value": "X-API-Key"}, {"key": "in", "value": "header"}]},
+						"url": {"raw": "{{baseUrl}}/{{version}}/users/:id", "variable": [{"key": "id", "value": ""}]}
+					}
+				}
+			]
+		},
+		{
+			"name": "Login",
+			"request": {
+				"method": "POST",
+				"auth": {"type": "noauth"},
+				"url": "{{baseUrl}}/login",
+				"body": {"mode": "urlencoded", "urlencoded": [{"key": "user", "value": "developer"}]}
+			}
+		}
+	]
+}`
+
+const environment = `{"name": "staging", "values": [
+	{"key": "baseUrl", "value": "https://staging.example.com", "enabled": true},
+	{"key": "userName", "value": "wave", "enabled": true}
+]}`
+
+func TestPostman(t *testing.T) {
+	imported, warnings, err := Postman([]byte(collection), []byte(environment))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name    string
+		request *driver.Request
+		body    string
+	}{{
+		name: "Users/Create user",
+		request: &driver.Request{Method: "POST", Base: "https://staging.example.com", Endpoint: "/v1/users?notify=true",
+			SuccessCode: 201, ContentType: "application/json", Auth: &driver.AuthConfig{Type: "bearer"},
+			Headers: map[string]string{"X-Trace": "{{traceId}}"}},
+		body: `{"name": "wave"}`,
+	}, {
+		name: "Users/Get user",
+		request: &driver.Request{Method: "GET", Base: "https://staging.example.com", Endpoint: "/v1/users/{id}",
+			SuccessCode: 200, Auth: &driver.AuthConfig{Type: "api-key", Header: "X-API-Key"}},
+	}, {
+		name: "Login",
+		request: &driver.Request{Method: "POST", Base: "https://staging.example.com", Endpoint: "/login",
+			SuccessCode: 200, ContentType: "application/x-www-form-urlencoded"},
+		body: "user=developer",
+	}}
+	if len(imported) != len(expected) {
+		t.Fatalf("Expected %d requests, but got %d", len(expected), len(imported))
+	}
+	for i, e := range expected {
+		if imported[i].Name != e.name {
+			t.Errorf("Expected the name %s, but got %s", e.name, imported[i].Name)
+		}
+		if !reflect.DeepEqual(imported[i].Request, e.request) {
+			t.Errorf("%s: expected %+v, but got %+v", e.name, e.request, imported[i].Request)
+		}
+		if string(imported[i].Body) != e.body {
+			t.Errorf("%s: expected the body %q, but got %q", e.name, e.body, imported[i].Body)
+		}
+	}
+
+	joined := strings.Join(warnings, "\n")
+	for _, warning := range []string{"prerequest script of Users", "auth type bearer", "auth type api-key",
+		"left as is: traceId"} {
+		if !strings.Contains(joined, warning) {
+			t.Errorf("Expected a warning about %q, but got %q", warning, warnings)
+		}
+	}
+
+	if _, _, err := Postman([]byte(`{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/"}}`),
+		nil); err == nil {
+		t.Errorf("Expected an error for a v1 collection")
+	}
+}
+
+func TestPostmanPathVariables(t *testing.T) {
+	collection := `{"item": [{"name": "Get item", "request": {"method": "GET", "url": {
+		"raw": "https://api.example.com/users/:idx/:id?sort=:id",
+		"variable": [{"key": "id", "value": "7"}, {"key": "idx", "value": "5"}]}}}]}`
+	imported, _, err := Postman([]byte(collection), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint := imported[0].Request.Endpoint; endpoint != "/users/5/7?sort=:id" {
+		t.Errorf("Expected the endpoint /users/5/7?sort=:id, but got %s", endpoint)
+	}
+}
+
+func TestPostmanSecrets(t *testing.T) {
+	collection := `{
+		"variable": [{"key": "baseUrl", "value": "https://api.example.com"}, {"key": "apiToken", "value": "t0k3n"}],
+		"item": [{"name": "Login", "request": {
+			"method": "POST",
+			"header": [{"key": "X-Session", "value": "{{session}}"}, {"key": "X-Token", "value": "{{apiToken}}"}],
+			"url": "{{baseUrl}}/login",
+			"body": {"mode": "urlencoded", "urlencoded": [{"key": "user", "value": "{{user}}"},
+				{"key": "pass", "value": "{{password}}"}]}
+		}}]
+	}`
+	environment := `{"values": [{"key": "user", "value": "developer"}, {"key": "password", "value": "hunter22"},
+		{"key": "session", "value": "s3ss10n", "type": "secret"}]}`
+	imported, warnings, err := Postman([]byte(collection), []byte(environment))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := imported[0].Request
+	expected := map[string]string{"X-Session": "{{session}}", "X-Token": "{{apiToken}}"}
+	if !reflect.DeepEqual(req.Headers, expected) {
+		t.Errorf("Expected the headers %v, but got %v", expected, req.Headers)
+	}
+	if body := string(imported[0].Body); body != "pass=%7B%7Bpassword%7D%7D&user=developer" {
+		t.Errorf("Expected the password to be left as is, but got the body %q", body)
+	}
+	joined := strings.Join(warnings, "\n")
+	if !strings.Contains(joined, "secret variables weren't written to the requests file") ||
+		!strings.Contains(joined, "apiToken, password, session") {
+		t.Errorf("Expected a warning about the secret variables, but got %q", warnings)
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxRecordedBody bounds the size of the response bodies kept to become expected bodies, larger responses are still
+// forwarded in full but recorded without one
+const maxRecordedBody = 1 << 20
+
+// RecordOptions filters the exchanges recorded by a Recorder:
+// ContentTypes: only exchanges whose response content type contains one of these, such as json, are recorded, every
+// exchange is recorded if it's empty
+type RecordOptions struct {
+	ContentTypes []string
+}
+
+// Recorder is a reverse proxy forwarding requests to an upstream API and converting every exchange into a request.
+// The observed status becomes the success code of the request and JSON responses become its expected body
+type Recorder struct {
+	proxy    *httputil.ReverseProxy
+	upstream *url.URL
+	opts     RecordOptions
+	record   func(imported *Imported, warnings []string)
+	mu       sync.Mutex
+	warned   map[string]bool
+}
+
+// recordedKey is the context key of the exchange being recorded
+type recordedKey struct{}
+
+// exchange is a request forwarded by the Recorder
+type exchange struct {
+	req  *http.Request
+	body []byte
+}
+
+// NewRecorder returns a Recorder forwarding requests to the upstream URL. The record function is called with every
+// recorded exchange and the warnings that weren't given yet, one exchange at a time
+func NewRecorder(upstream string, opts RecordOptions, record func(imported *Imported,
+	warnings []string)) (*Recorder, error) {
+	u, err := url.Parse(strings.TrimSuffix(upstream, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q isn't an absolute HTTP(S) URL", upstream)
+	}
+	r := &Recorder{upstream: u, opts: opts, record: record, warned: make(map[string]bool)}
+	r.proxy = httputil.NewSingleHostReverseProxy(u)
+	director := r.proxy.Director
+	r.proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = u.Host
+		// Let the transport decompress the response so that its body can be recorded
+		req.Header.Del("Accept-Encoding")
+	}
+	r.proxy.ModifyResponse = r.recordResponse
+	return r, nil
+}
+
+// ServeHTTP forwards the request to the upstream API
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_ = req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	ctx := context.WithValue(req.Context(), recordedKey{}, &exchange{req: req.Clone(req.Context()), body: body})
+	r.proxy.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// recordResponse records the exchange once the response was forwarded to the client. The response is streamed to the
+// client as it arrives, keeping a copy of its body of up to maxRecordedBody bytes
+func (r *Recorder) recordResponse(resp *http.Response) error {
+	ex, ok := resp.Request.Context().Value(recordedKey{}).(*exchange)
+	if !ok || !matchesContentType(resp.Header.Get("Content-Type"), r.opts.ContentTypes) {
+		return nil
+	}
+	status := resp.StatusCode
+	resp.Body = &recordingBody{ReadCloser: resp.Body, done: func(body []byte, truncated bool) {
+		imported, warnings := r.convert(ex, status, body)
+		if truncated {
+			warnings = append(warnings, fmt.Sprintf("responses larger than %d bytes were recorded without an "+
+				"expect file", maxRecordedBody))
+		}
+		r.save(imported, warnings)
+	}}
+	return nil
+}
+
+// save passes a recorded exchange to the record function along with the warnings that weren't given yet
+func (r *Recorder) save(imported *Imported, warnings []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var fresh []string
+	for _, warning := range warnings {
+		if !r.warned[warning] {
+			r.warned[warning] = true
+			fresh = append(fresh, warning)
+		}
+	}
+	r.record(imported, fresh)
+}
+
+// recordingBody forwards the body of a response while copying up to maxRecordedBody bytes of it, calling done once
+// the body was read to the end or closed. The copy is only passed to done if the whole body was read
+type recordingBody struct {
+	io.ReadCloser
+	copied    bytes.Buffer
+	complete  bool
+	truncated bool
+	once      sync.Once
+	done      func(body []byte, truncated bool)
+}
+
+// Read reads from the response body, copying what was read
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.truncated {
+		if b.copied.Len()+n > maxRecordedBody {
+			b.truncated = true
+			b.copied = bytes.Buffer{}
+		} else {
+			b.copied.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.complete = true
+		b.finish()
+	}
+	return n, err
+}
+
+// Close closes the response body, recording the exchange if it wasn't read to the end
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+// finish calls done once
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		var body []byte
+		if b.complete && !b.truncated {
+			body = b.copied.Bytes()
+		}
+		b.done(body, b.truncated)
+	})
+}
+
+// convert converts an exchange into a request
+func (r *Recorder) convert(ex *exchange, status int, respBody []byte) (*Imported, []string) {
+	endpoint := r.upstream.EscapedPath() + ex.req.URL.EscapedPath()
+	if endpoint == "" {
+		endpoint = "/"
+	}
+	if ex.req.URL.RawQuery != "" {
+		endpoint += "?" + ex.req.URL.RawQuery
+	}
+	req := &driver.Request{
+		Method:      ex.req.Method,
+		Base:        r.upstream.Scheme + "://" + r.upstream.Host,
+		Endpoint:    endpoint,
+		SuccessCode: status,
+	}
+
+	var warnings []string
+	for name, values := range ex.req.Header {
+		lower := strings.ToLower(name)
+		separator := ", "
+		if lower == "cookie" {
+			separator = "; "
+		}
+		value := strings.Join(values, separator)
+		switch {
+		case harSkippedHeaders[lower] || strings.HasPrefix(lower, "x-forwarded-"):
+			// Headers set by the client or the proxy
+		case lower == "content-type":
+			req.ContentType = value
+		case lower == "authorization":
+			warning := authWarning(req, value)
+			if req.Auth == nil {
+				setHeader(req, name, value)
+			}
+			warnings = append(warnings, warning)
+		default:
+			if lower == "cookie" {
+				warnings = append(warnings, "session cookies were recorded as Cookie headers and may expire")
+			}
+			setHeader(req, name, value)
+		}
+	}
+
+	imported := &Imported{Request: req, Body: ex.body}
+	// Only JSON responses can be compared with an expect file
+	var indented bytes.Buffer
+	if len(respBody) > 0 && json.Indent(&indented, respBody, "", "    ") == nil {
+		imported.Expect = append(indented.Bytes(), '\n')
+	}
+	return imported, warnings
+}
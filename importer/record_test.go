@@ -0,0 +1,212 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package importer
+
+import (
+	"github.com/fercevik729/Wave/driver"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":1,` + string(body[1:])))
+		case "/api/users/1":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":1,"name":"wave"}`))
+		default:
+			w.Header().Set("Content-Type", "text/css")
+			_, _ = w.Write([]byte("body {}"))
+		}
+	}))
+	defer upstream.Close()
+
+	var (
+		mu       sync.Mutex
+		imported []*Imported
+		warnings []string
+	)
+	recorder, err := NewRecorder(upstream.URL+"/api", RecordOptions{ContentTypes: []string{"json"}},
+		func(imp *Imported, warns []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			imported = append(imported, imp)
+			warnings = append(warnings, warns...)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+
+	send := func(method, path, body string) string {
+		req, _ := http.NewRequest(method, proxy.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return string(data)
+	}
+	// The client gets the upstream responses
+	if resp := send("POST", "/users", `{"name":"wave"}`); resp != `{"id":1,"name":"wave"}` {
+		t.Errorf("Expected the upstream response, but got %s", resp)
+	}
+	send("GET", "/users/1?expand=true", "")
+	send("GET", "/style.css", "")
+
+	if len(imported) != 2 {
+		t.Fatalf("Expected 2 recorded requests, but got %d", len(imported))
+	}
+	expected := &driver.Request{
+		Method:      "POST",
+		Base:        upstream.URL,
+		Endpoint:    "/api/users",
+		SuccessCode: 201,
+		ContentType: "application/json",
+		Auth:        &driver.AuthConfig{Type: "bearer"},
+		Headers:     map[string]string{"User-Agent": "Go-http-client/1.1"},
+	}
+	if !reflect.DeepEqual(imported[0].Request, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, imported[0].Request)
+	}
+	if string(imported[0].Body) != `{"name":"wave"}` {
+		t.Errorf("Expected the request body to be recorded, but got %s", imported[0].Body)
+	}
+	if imported[1].Request.Endpoint != "/api/users/1?expand=true" || imported[1].Request.SuccessCode != 200 {
+		t.Errorf("Expected GET /api/users/1?expand=true, but got %+v", imported[1].Request)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Expected the bearer token warning once, but got %q", warnings)
+	}
+
+	// The responses become expect files matching the upstream responses
+	dir := t.TempDir()
+	reqFile := filepath.Join(dir, "reqs.yaml")
+	credFile := filepath.Join(dir, "cred.yaml")
+	if err := ioutil.WriteFile(credFile, []byte("token: \"secret\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Append(reqFile, filepath.Join(dir, "data"), imported); err != nil {
+		t.Fatal(err)
+	}
+	reqs, chain := driver.New(reqFile, credFile)
+	if reqs[1].ExpectFile != filepath.ToSlash(filepath.Join(dir, "data", "request-2.expect.json")) {
+		t.Errorf("Expected an expect file for the GET request, but got %q", reqs[1].ExpectFile)
+	}
+	if successes := driver.Whirlpool(1, reqs[1:], false, "", chain, nil); successes != 1 {
+		t.Errorf("Expected the recorded request to match its expect file when replayed")
+	}
+}
+
+func TestRecorderStreaming(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/large" {
+			_, _ = w.Write([]byte(`{"data":"` + strings.Repeat("a", maxRecordedBody) + `"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[`))
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = w.Write([]byte(`1,2]}`))
+	}))
+	defer upstream.Close()
+
+	var (
+		mu       sync.Mutex
+		imported []*Imported
+		warnings []string
+	)
+	recorder, err := NewRecorder(upstream.URL, RecordOptions{}, func(imp *Imported, warns []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		imported = append(imported, imp)
+		warnings = append(warnings, warns...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+
+	// The start of the response reaches the client before the upstream API finished it
+	resp, err := http.Get(proxy.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := make([]byte, len(`{"items":[`))
+	read := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(resp.Body, start)
+		read <- err
+	}()
+	select {
+	case err := <-read:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the response to be streamed to the client")
+	}
+	close(release)
+	rest, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(start)+string(rest) != `{"items":[1,2]}` {
+		t.Errorf("Expected the upstream response, but got %s%s", start, rest)
+	}
+
+	// Larger responses are forwarded in full but recorded without an expected body
+	resp, err = http.Get(proxy.URL + "/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if n != int64(maxRecordedBody+len(`{"data":""}`)) {
+		t.Errorf("Expected the whole response, but got %d bytes", n)
+	}
+
+	// The exchange is recorded once the proxy read the whole response, which may be after the client did
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		mu.Lock()
+		n := len(imported)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(imported) != 2 {
+		t.Fatalf("Expected 2 recorded requests, but got %d", len(imported))
+	}
+	if expected := "{\n    \"items\": [\n        1,\n        2\n    ]\n}\n"; string(imported[0].Expect) != expected {
+		t.Errorf("Expected the streamed response to be recorded, but got %q", imported[0].Expect)
+	}
+	if imported[1].Expect != nil || len(warnings) != 1 || !strings.Contains(warnings[0], "without an expect file") {
+		t.Errorf("Expected the large response to be recorded without an expect file, but got %d bytes and %q",
+			len(imported[1].Expect), warnings)
+	}
+}
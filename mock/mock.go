@@ -0,0 +1,185 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options holds the settings of a mock server:
+// Latency: delay added before every response
+// ErrorRate: fraction of requests, between 0 and 1, that get a 500 response instead of their mocked response
+type Options struct {
+	Latency   time.Duration
+	ErrorRate float64
+}
+
+// Server is an HTTP server answering every request defined in the requests YAML file with its success code and the
+// contents of its expect file. Endpoints using {id} notation match any id
+type Server struct {
+	routes []*route
+	opts   Options
+	mu     sync.Mutex
+	rand   *rand.Rand
+}
+
+// route is a request answered by a Server
+type route struct {
+	req      *driver.Request
+	segments []string
+	query    string
+}
+
+// New returns a Server answering the requests of the requests YAML file, decrypting encrypted expect files with the
+// passphrase of the KeySource
+func New(reqFile string, key driver.KeySource, opts *Options) *Server {
+	s := &Server{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	if opts != nil {
+		s.opts = *opts
+	}
+	for _, req := range driver.ReadRequests(reqFile, key) {
+		s.routes = append(s.routes, newRoute(req))
+	}
+	return s
+}
+
+// newRoute returns the route of a request, prefixing its endpoint with the path of its base
+func newRoute(req *driver.Request) *route {
+	path, query := req.Endpoint, ""
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+	if u, err := url.Parse(req.Base); err == nil {
+		path = strings.TrimSuffix(u.Path, "/") + path
+	}
+	return &route{req: req, segments: strings.Split(strings.Trim(path, "/"), "/"), query: query}
+}
+
+// ServeHTTP answers the request with the mocked response of the matching route
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	code := s.respond(w, r)
+	fmt.Printf("[%s] \"%s %s %s\" %d, %s\n", start.Format("2/Jan/2006:15:04:05 -0700"), r.Method,
+		r.URL.RequestURI(), r.Proto, code, time.Since(start))
+}
+
+// respond writes the mocked response and returns its status code
+func (s *Server) respond(w http.ResponseWriter, r *http.Request) int {
+	if s.opts.Latency > 0 {
+		time.Sleep(s.opts.Latency)
+	}
+	matched := s.match(r)
+	if matched == nil {
+		return writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("no request is defined for %s %s", r.Method, r.URL.Path),
+		})
+	}
+	if s.opts.ErrorRate > 0 {
+		s.mu.Lock()
+		failed := s.rand.Float64() < s.opts.ErrorRate
+		s.mu.Unlock()
+		if failed {
+			return writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "injected error"})
+		}
+	}
+
+	req := matched.req
+	code := req.SuccessCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	// Authentication requests hand out a token where the request captures it from
+	if req.IsAuth && req.ExpectFile == "" {
+		if req.TokenHeader != "" {
+			w.Header().Set(req.TokenHeader, "Bearer mock-token")
+			w.WriteHeader(code)
+			return code
+		}
+		return writeJSON(w, code, token(req.TokenField))
+	}
+	if body := req.ExpectedBody(); len(body) > 0 {
+		if json.Valid(body) {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(code)
+		_, _ = w.Write(body)
+		return code
+	}
+	w.WriteHeader(code)
+	return code
+}
+
+// match returns the route matching the method and path of the request, preferring the routes with the most literal
+// segments and the ones whose query matches
+func (s *Server) match(r *http.Request) *route {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var best *route
+	bestScore := -1
+	for _, candidate := range s.routes {
+		if candidate.req.Method != r.Method || len(candidate.segments) != len(segments) {
+			continue
+		}
+		score := 0
+		for i, segment := range candidate.segments {
+			if segment == segments[i] {
+				score += 2
+			} else if !strings.Contains(segment, "{id}") || !matchesID(segment, segments[i]) {
+				score = -1
+				break
+			}
+		}
+		if score < 0 {
+			continue
+		}
+		if candidate.query != "" && candidate.query == r.URL.RawQuery {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// matchesID reports whether a path segment matches a segment of an endpoint using {id} notation, such as
+// user-{id}.json
+func matchesID(pattern, segment string) bool {
+	i := strings.Index(pattern, "{id}")
+	prefix, suffix := pattern[:i], pattern[i+len("{id}"):]
+	return len(segment) > len(prefix)+len(suffix) && strings.HasPrefix(segment, prefix) &&
+		strings.HasSuffix(segment, suffix)
+}
+
+// token returns a JSON body holding a token at the dot separated path of the token field
+func token(field string) interface{} {
+	if field == "" {
+		field = "token"
+	}
+	keys := strings.Split(field, ".")
+	var body interface{} = "mock-token"
+	for i := len(keys) - 1; i >= 0; i-- {
+		body = map[string]interface{}{keys[i]: body}
+	}
+	return body
+}
+
+// writeJSON writes a JSON response and returns its status code
+func writeJSON(w http.ResponseWriter, code int, body interface{}) int {
+	data, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+	return code
+}
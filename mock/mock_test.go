@@ -0,0 +1,106 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package mock
+
+import (
+	"github.com/fercevik729/Wave/driver"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestMock(t *testing.T) {
+	dir := t.TempDir()
+	reqFile := filepath.Join(dir, "reqs.yaml")
+	credFile := filepath.Join(dir, "cred.yaml")
+	expectFile := filepath.ToSlash(filepath.Join(dir, "user.json"))
+	requests := `login:
+  method: POST
+  base: http://mock/v1
+  endpoint: /login
+  success-code: 200
+  is-auth: true
+  token-field: data.access_token
+users:
+  method: GET
+  base: http://mock/v1
+  endpoint: /users/{id}
+  id-range: ["1", "3"]
+  success-code: 200
+  r-token: true
+  expect-file: ` + expectFile + `
+me:
+  method: GET
+  base: http://mock/v1
+  endpoint: /users/me
+  success-code: 204
+created:
+  method: POST
+  base: http://mock/v1
+  endpoint: /users
+  success-code: 201
+`
+	if err := ioutil.WriteFile(reqFile, []byte(requests), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(expectFile, []byte(`{"name": "wave"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(credFile, []byte("user: \"developer\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(New(reqFile, nil, nil))
+	defer server.Close()
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{"GET", "/v1/users/42", 200, `{"name": "wave"}`},
+		{"GET", "/v1/users/me", 204, ""},
+		{"POST", "/v1/users", 201, ""},
+		{"POST", "/v1/login", 200, `{"data":{"access_token":"mock-token"}}`},
+		{"DELETE", "/v1/users/42", 404, `{"error":"no request is defined for DELETE /v1/users/42"}`},
+		{"GET", "/users/42", 404, `{"error":"no request is defined for GET /users/42"}`},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, server.URL+test.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != test.code || string(body) != test.body {
+			t.Errorf("%s %s: expected %d %s, but got %d %s", test.method, test.path, test.code, test.body,
+				resp.StatusCode, body)
+		}
+	}
+
+	// The requests succeed against their own mock
+	reqs, chain := driver.New(reqFile, credFile)
+	for _, req := range reqs {
+		req.Base = server.URL + "/v1"
+	}
+	if successes := driver.Whirlpool(1, reqs, false, "", chain, nil); successes != len(reqs) {
+		t.Errorf("Expected %d successes against the mock, but got %d", len(reqs), successes)
+	}
+
+	// Injected errors
+	errServer := httptest.NewServer(New(reqFile, nil, &Options{ErrorRate: 1}))
+	defer errServer.Close()
+	resp, err := http.Get(errServer.URL + "/v1/users/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected an injected error, but got %d", resp.StatusCode)
+	}
+}
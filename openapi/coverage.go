@@ -0,0 +1,249 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Coverage records which operations of a specification were exercised by the requests of a run and the status codes
+// they responded with. It is safe for concurrent use
+type Coverage struct {
+	sync.Mutex
+	endpoints []*Endpoint
+	basePaths []string
+	observed  map[*Endpoint]map[int]int
+	unmatched map[string]int
+}
+
+// CoverageReport is the coverage of a specification after a run:
+// Operations: every operation of the specification with the status codes observed
+// Untested: operations no request was sent to, formatted as METHOD /path
+// Unmatched: requests that match no operation, formatted as METHOD /path, with the number of times they were sent
+type CoverageReport struct {
+	Operations []*OperationCoverage `json:"operations"`
+	Untested   []string             `json:"untested"`
+	Unmatched  map[string]int       `json:"unmatched"`
+}
+
+// OperationCoverage is the coverage of a single operation:
+// Documented: the status codes documented by the specification, such as 200, 4XX or default
+// Observed: the number of responses by status code
+// Undocumented: the observed status codes the specification doesn't document
+type OperationCoverage struct {
+	Method       string      `json:"method"`
+	Path         string      `json:"path"`
+	OperationID  string      `json:"operationId,omitempty"`
+	Documented   []string    `json:"documented"`
+	Observed     map[int]int `json:"observed"`
+	Undocumented []int       `json:"undocumented,omitempty"`
+}
+
+// NewCoverage returns an empty coverage of the operations of the specification
+func NewCoverage(spec *Spec) (*Coverage, error) {
+	endpoints, err := spec.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	c := &Coverage{
+		endpoints: endpoints,
+		observed:  make(map[*Endpoint]map[int]int),
+		unmatched: make(map[string]int),
+	}
+	// Requests include the path of the server URL, such as /v1, that the paths of the specification are relative to
+	for _, server := range spec.Servers {
+		rawURL := server.URL
+		for name, variable := range server.Variables {
+			rawURL = strings.ReplaceAll(rawURL, "{"+name+"}", variable.Default)
+		}
+		if u, err := url.Parse(rawURL); err == nil && strings.Trim(u.Path, "/") != "" {
+			c.basePaths = append(c.basePaths, "/"+strings.Trim(u.Path, "/"))
+		}
+	}
+	sort.Slice(c.basePaths, func(i, j int) bool {
+		return len(c.basePaths[i]) > len(c.basePaths[j])
+	})
+	return c, nil
+}
+
+// Record records the status code of a request sent to the URL
+func (c *Coverage) Record(method, rawURL string, code int) {
+	method = strings.ToUpper(method)
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.EscapedPath()
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	endpoint := c.match(method, path)
+	if endpoint == nil {
+		c.unmatched[method+" "+path]++
+		return
+	}
+	if c.observed[endpoint] == nil {
+		c.observed[endpoint] = make(map[int]int)
+	}
+	c.observed[endpoint][code]++
+}
+
+// match returns the operation of the method whose path template matches the path, preferring the templates with the
+// most literal segments so that /users/me is matched before /users/{id}
+func (c *Coverage) match(method, path string) *Endpoint {
+	candidates := []string{path}
+	for _, base := range c.basePaths {
+		if path == base || strings.HasPrefix(path, base+"/") {
+			candidates = append([]string{strings.TrimPrefix(path, base)}, candidates...)
+		}
+	}
+	for _, candidate := range candidates {
+		var best *Endpoint
+		bestLiterals := -1
+		for _, e := range c.endpoints {
+			if e.Method != method {
+				continue
+			}
+			if literals, ok := matchTemplate(e.Path, candidate); ok && literals > bestLiterals {
+				best, bestLiterals = e, literals
+			}
+		}
+		if best != nil {
+			return best
+		}
+	}
+	return nil
+}
+
+// matchTemplate reports whether the path matches the path template and returns its number of literal segments
+func matchTemplate(template, path string) (int, bool) {
+	tSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(tSegments) != len(pSegments) {
+		return 0, false
+	}
+	literals := 0
+	for i, segment := range tSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pSegments[i] == "" {
+				return 0, false
+			}
+			continue
+		}
+		unescaped, err := url.PathUnescape(pSegments[i])
+		if err != nil {
+			unescaped = pSegments[i]
+		}
+		if segment != unescaped {
+			return 0, false
+		}
+		literals++
+	}
+	return literals, true
+}
+
+// Report returns the coverage of the specification so far
+func (c *Coverage) Report() *CoverageReport {
+	c.Lock()
+	defer c.Unlock()
+	report := &CoverageReport{Untested: []string{}, Unmatched: make(map[string]int, len(c.unmatched))}
+	for _, e := range c.endpoints {
+		op := &OperationCoverage{
+			Method:      e.Method,
+			Path:        e.Path,
+			OperationID: e.Operation.OperationID,
+			Observed:    make(map[int]int),
+		}
+		for code := range e.Operation.Responses {
+			op.Documented = append(op.Documented, code)
+		}
+		sort.Strings(op.Documented)
+		for code, n := range c.observed[e] {
+			op.Observed[code] = n
+			if !documented(e.Operation, code) {
+				op.Undocumented = append(op.Undocumented, code)
+			}
+		}
+		sort.Ints(op.Undocumented)
+		if len(op.Observed) == 0 {
+			report.Untested = append(report.Untested, e.Method+" "+e.Path)
+		}
+		report.Operations = append(report.Operations, op)
+	}
+	for request, n := range c.unmatched {
+		report.Unmatched[request] = n
+	}
+	return report
+}
+
+// documented reports whether the status code is documented by the operation, either explicitly, by its range such
+// as 4XX or by a default response
+func documented(op *Operation, code int) bool {
+	status := strconv.Itoa(code)
+	for documentedCode := range op.Responses {
+		documentedCode = strings.ToUpper(documentedCode)
+		if documentedCode == status || documentedCode == "DEFAULT" ||
+			(len(status) == 3 && documentedCode == status[:1]+"XX") {
+			return true
+		}
+	}
+	return false
+}
+
+// Tested returns the number of operations that were sent at least one request
+func (r *CoverageReport) Tested() int {
+	return len(r.Operations) - len(r.Untested)
+}
+
+// Write writes a human readable summary of the report
+func (r *CoverageReport) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "OpenAPI coverage: %d out of %d operations tested\n", r.Tested(), len(r.Operations))
+	if len(r.Untested) > 0 {
+		b.WriteString("Untested operations:\n")
+		for _, op := range r.Untested {
+			fmt.Fprintf(&b, "\t%s\n", op)
+		}
+	}
+
+	var undocumented []string
+	for _, op := range r.Operations {
+		for _, code := range op.Undocumented {
+			undocumented = append(undocumented, fmt.Sprintf("%s %s responded with %d %d time(s), documented: %s",
+				op.Method, op.Path, code, op.Observed[code], strings.Join(op.Documented, ", ")))
+		}
+	}
+	if len(undocumented) > 0 {
+		b.WriteString("Undocumented status codes:\n")
+		for _, line := range undocumented {
+			fmt.Fprintf(&b, "\t%s\n", line)
+		}
+	}
+
+	if len(r.Unmatched) > 0 {
+		requests := make([]string, 0, len(r.Unmatched))
+		for request := range r.Unmatched {
+			requests = append(requests, request)
+		}
+		sort.Strings(requests)
+		b.WriteString("Requests matching no operation:\n")
+		for _, request := range requests {
+			fmt.Fprintf(&b, "\t%s sent %d time(s)\n", request, r.Unmatched[request])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// JSON returns the report as indented JSON
+func (r *CoverageReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "    ")
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package openapi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	spec, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverage, err := NewCoverage(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverage.Record("GET", "https://api.example.com/v1/users?limit=10", 200)
+	coverage.Record("get", "http://localhost:8080/v1/users/42", 200)
+	coverage.Record("GET", "http://localhost:8080/v1/users/43", 404)
+	coverage.Record("DELETE", "/users/42", 204)
+	coverage.Record("GET", "https://api.example.com/v1/health", 200)
+	coverage.Record("PUT", "https://api.example.com/v1/users/42", 200)
+
+	report := coverage.Report()
+	if report.Tested() != 3 {
+		t.Errorf("Expected 3 tested operations, but got %d", report.Tested())
+	}
+	expectedUntested := []string{"GET /orgs/{orgId}/tree/{nodeId}", "POST /users"}
+	if !reflect.DeepEqual(report.Untested, expectedUntested) {
+		t.Errorf("Expected the untested operations %q, but got %q", expectedUntested, report.Untested)
+	}
+	expectedUnmatched := map[string]int{"GET /v1/health": 1, "PUT /v1/users/42": 1}
+	if !reflect.DeepEqual(report.Unmatched, expectedUnmatched) {
+		t.Errorf("Expected the unmatched requests %v, but got %v", expectedUnmatched, report.Unmatched)
+	}
+	for _, op := range report.Operations {
+		if op.Method == "GET" && op.Path == "/users/{userId}" {
+			if !reflect.DeepEqual(op.Observed, map[int]int{200: 1, 404: 1}) ||
+				!reflect.DeepEqual(op.Undocumented, []int{404}) {
+				t.Errorf("Expected 404 to be an undocumented status code of getUser, but got %+v", op)
+			}
+		} else if len(op.Undocumented) > 0 {
+			t.Errorf("Expected no undocumented status codes for %s %s, but got %v", op.Method, op.Path,
+				op.Undocumented)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"3 out of 5 operations tested", "POST /users\n",
+		"GET /users/{userId} responded with 404", "PUT /v1/users/42 sent 1 time(s)"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected the summary to contain %q, but got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestMatchTemplate(t *testing.T) {
+	tests := []struct {
+		template, path string
+		literals       int
+		matches        bool
+	}{
+		{"/users/{id}", "/users/42", 1, true},
+		{"/users/me", "/users/me", 2, true},
+		{"/users/{id}", "/users/", 0, false},
+		{"/users/{id}", "/users/42/posts", 0, false},
+		{"/files/{name}", "/files/a%20b", 1, true},
+		{"/a b", "/a%20b", 1, true},
+	}
+	for _, test := range tests {
+		literals, matches := matchTemplate(test.template, test.path)
+		if matches != test.matches || literals != test.literals {
+			t.Errorf("Expected %s to match %s: %v with %d literal segments, but got %v with %d", test.path,
+				test.template, test.matches, test.literals, matches, literals)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fercevik729/Wave/driver"
+	"github.com/fercevik729/Wave/importer"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathParam matches the {parameters} of a path
+var pathParam = regexp.MustCompile(`{([^{}]+)}`)
+
+// maxSampleDepth limits how deep sample bodies of nested schemas are generated
+const maxSampleDepth = 6
+
+// Generate converts every operation of the specification into a request named after its operationId. The last path
+// parameter of an operation becomes the {id} placeholder with its example as the id range, example bodies are used as
+// data files, the first documented 2xx status as the success code and the schema of its JSON response as the schema
+// file. The base overrides the URL of the first server of the specification
+func Generate(spec *Spec, base string) ([]*importer.Imported, []string, error) {
+	if base == "" {
+		base = spec.BaseURL()
+	}
+	if !strings.Contains(base, "://") {
+		return nil, nil, fmt.Errorf("the server URL %q isn't absolute, set the base URL of the API", base)
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	endpoints, err := spec.Endpoints()
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		imported []*importer.Imported
+		warnings []string
+		warned   = make(map[string]bool)
+	)
+	for _, e := range endpoints {
+		imp, warns, err := generate(spec, e, base)
+		if err != nil {
+			return nil, warnings, fmt.Errorf("%s %s: %v", e.Method, e.Path, err)
+		}
+		imported = append(imported, imp)
+		// Operations share most warnings, give each once
+		for _, warning := range warns {
+			if !warned[warning] {
+				warned[warning] = true
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+	return imported, warnings, nil
+}
+
+// generate converts a single operation into a request
+func generate(spec *Spec, e *Endpoint, base string) (*importer.Imported, []string, error) {
+	var warnings []string
+	op := e.Operation
+	req := &driver.Request{Method: e.Method, Base: base}
+	name := op.OperationID
+	if name == "" {
+		name = strings.ToLower(e.Method) + " " + e.Path
+	}
+
+	// Path parameters, the last one becomes the id placeholder
+	endpoint := e.Path
+	placeholders := pathParam.FindAllStringSubmatch(e.Path, -1)
+	for i, match := range placeholders {
+		param := findParameter(e.Parameters, "path", match[1])
+		value := "1"
+		if param != nil {
+			value = parameterExample(spec, param)
+		}
+		if i == len(placeholders)-1 {
+			endpoint = strings.Replace(endpoint, match[0], "{id}", 1)
+			req.IdRange = []string{value}
+			continue
+		}
+		endpoint = strings.Replace(endpoint, match[0], url.PathEscape(value), 1)
+	}
+
+	// Required query, header and cookie parameters
+	query := url.Values{}
+	var cookies []string
+	for _, param := range e.Parameters {
+		if !param.Required {
+			continue
+		}
+		value := parameterExample(spec, param)
+		switch param.In {
+		case "query":
+			query.Add(param.Name, value)
+		case "header":
+			if strings.EqualFold(param.Name, "Content-Type") || strings.EqualFold(param.Name, "Authorization") {
+				continue
+			}
+			if req.Headers == nil {
+				req.Headers = make(map[string]string)
+			}
+			req.Headers[param.Name] = value
+		case "cookie":
+			cookies = append(cookies, param.Name+"="+value)
+		}
+	}
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	if len(cookies) > 0 {
+		if req.Headers == nil {
+			req.Headers = make(map[string]string)
+		}
+		req.Headers["Cookie"] = strings.Join(cookies, "; ")
+	}
+	req.Endpoint = endpoint
+
+	body, err := requestBody(spec, op, req)
+	if err != nil {
+		return nil, warnings, err
+	}
+	schema, warning, err := successResponse(spec, op, req)
+	if err != nil {
+		return nil, warnings, err
+	}
+	if warning != "" {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", name, warning))
+	}
+	if warning := securityAuth(spec, op, req); warning != "" {
+		warnings = append(warnings, warning)
+	}
+	return &importer.Imported{Name: name, Request: req, Body: body, Schema: schema}, warnings, nil
+}
+
+// requestBody returns an example body of the operation, preferring JSON media types, and sets the content type
+func requestBody(spec *Spec, op *Operation, req *driver.Request) ([]byte, error) {
+	body, err := spec.RequestBody(op)
+	if err != nil || body == nil || len(body.Content) == 0 {
+		return nil, err
+	}
+	mediaType := preferredMediaType(body.Content)
+	media := body.Content[mediaType]
+	req.ContentType = mediaType
+	if media == nil {
+		return nil, nil
+	}
+
+	example := mediaExample(spec, media)
+	switch {
+	case isJSON(mediaType):
+		return json.MarshalIndent(example, "", "    ")
+	case mediaType == "application/x-www-form-urlencoded":
+		form := url.Values{}
+		if fields, ok := example.(map[string]interface{}); ok {
+			for key, value := range fields {
+				form.Add(key, fmt.Sprint(value))
+			}
+		}
+		return []byte(form.Encode()), nil
+	}
+	if text, ok := example.(string); ok {
+		return []byte(text), nil
+	}
+	return nil, nil
+}
+
+// successResponse sets the success code of the request to the first documented 2xx status and returns the JSON schema
+// of its response body, if it has one
+func successResponse(spec *Spec, op *Operation, req *driver.Request) ([]byte, string, error) {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	success := ""
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			success = code
+			break
+		}
+	}
+	warning := ""
+	if success == "" {
+		req.SuccessCode = 200
+		warning = "no 2xx response is documented, the success code was set to 200"
+		return nil, warning, nil
+	}
+	// Ranges such as 2XX
+	req.SuccessCode, _ = strconv.Atoi(strings.NewReplacer("X", "0", "x", "0").Replace(success))
+
+	resp, err := spec.Response(op, success)
+	if err != nil || resp == nil {
+		return nil, warning, err
+	}
+	if len(resp.Content) == 0 {
+		return nil, warning, nil
+	}
+	mediaType := preferredMediaType(resp.Content)
+	media := resp.Content[mediaType]
+	if !isJSON(mediaType) || media == nil || media.Schema == nil {
+		return nil, warning, nil
+	}
+	schema, err := spec.ResolveSchema(media.Schema)
+	if err != nil {
+		return nil, warning, err
+	}
+	out, err := json.MarshalIndent(schema, "", "    ")
+	return out, warning, err
+}
+
+// securityAuth sets the auth strategy matching the security scheme of the operation and returns a warning about the
+// secrets it needs
+func securityAuth(spec *Spec, op *Operation, req *driver.Request) string {
+	name, scheme := spec.SecurityScheme(op)
+	if scheme == nil {
+		return ""
+	}
+	switch {
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"):
+		req.Auth = &driver.AuthConfig{Type: "bearer"}
+		return "bearer tokens are used by auth type bearer, add the token to the credentials file"
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+		req.Auth = &driver.AuthConfig{Type: "basic"}
+		return "basic auth is used by auth type basic, add the user and pass to the credentials file"
+	case scheme.Type == "apiKey" && scheme.In == "header":
+		req.Auth = &driver.AuthConfig{Type: "api-key", Header: scheme.Name}
+		return "API keys are used by auth type api-key, add the api-key to the credentials file"
+	case scheme.Type == "apiKey" && scheme.In == "query":
+		req.Auth = &driver.AuthConfig{Type: "api-key", Query: scheme.Name}
+		return "API keys are used by auth type api-key, add the api-key to the credentials file"
+	case scheme.Type == "oauth2" || scheme.Type == "openIdConnect":
+		req.Auth = &driver.AuthConfig{Type: "bearer"}
+		return "OAuth 2.0 tokens are used by auth type bearer, add the oauth2 settings to the credentials file"
+	}
+	return fmt.Sprintf("the security scheme %s isn't supported and was ignored", name)
+}
+
+// findParameter returns the parameter with the location and name
+func findParameter(params []*Parameter, in, name string) *Parameter {
+	for _, param := range params {
+		if param.In == in && param.Name == name {
+			return param
+		}
+	}
+	return nil
+}
+
+// parameterExample returns the example value of a parameter as a string
+func parameterExample(spec *Spec, param *Parameter) string {
+	example := param.Example
+	if example == nil {
+		example = namedExample(spec, param.Examples)
+	}
+	if example == nil {
+		schema, _ := spec.ResolveSchema(param.Schema)
+		example = sample(schema, 0)
+	}
+	if example == nil {
+		return "1"
+	}
+	return fmt.Sprint(normalize(example))
+}
+
+// mediaExample returns the example of a body, generating one from its schema if none is documented
+func mediaExample(spec *Spec, media *MediaType) interface{} {
+	if media.Example != nil {
+		return normalize(media.Example)
+	}
+	if example := namedExample(spec, media.Examples); example != nil {
+		return normalize(example)
+	}
+	schema, err := spec.ResolveSchema(media.Schema)
+	if err != nil {
+		return nil
+	}
+	return sample(schema, 0)
+}
+
+// namedExample returns the value of the first named example in alphabetical order
+func namedExample(spec *Spec, examples map[string]*Example) interface{} {
+	names := make([]string, 0, len(examples))
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		example := examples[name]
+		if example != nil && example.Ref != "" {
+			example = spec.Components.Examples[refName(example.Ref, "examples")]
+		}
+		if example != nil && example.Value != nil {
+			return example.Value
+		}
+	}
+	return nil
+}
+
+// sample generates an example value from a resolved schema
+func sample(schema *Schema, depth int) interface{} {
+	if schema == nil || depth > maxSampleDepth {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return normalize(schema.Example)
+	case schema.Default != nil:
+		return normalize(schema.Default)
+	case len(schema.Enum) > 0:
+		return normalize(schema.Enum[0])
+	case len(schema.AllOf) > 0:
+		// Merge the properties of every schema
+		merged := map[string]interface{}{}
+		for _, sub := range schema.AllOf {
+			if object, ok := sample(sub, depth+1).(map[string]interface{}); ok {
+				for key, value := range object {
+					merged[key] = value
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return sample(schema.OneOf[0], depth+1)
+	case len(schema.AnyOf) > 0:
+		return sample(schema.AnyOf[0], depth+1)
+	}
+
+	switch schema.Type {
+	case "array":
+		item := sample(schema.Items, depth+1)
+		if item == nil {
+			return []interface{}{}
+		}
+		return []interface{}{item}
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			return "2022-01-01T00:00:00Z"
+		case "date":
+			return "2022-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		case "uri", "url":
+			return "https://example.com"
+		}
+		return "string"
+	case "integer":
+		if schema.Minimum != nil {
+			return int(*schema.Minimum)
+		}
+		return 1
+	case "number":
+		if schema.Minimum != nil {
+			return *schema.Minimum
+		}
+		return 1.5
+	case "boolean":
+		return true
+	}
+	if schema.Type == "object" || schema.Properties != nil {
+		object := map[string]interface{}{}
+		for name, property := range schema.Properties {
+			object[name] = sample(property, depth+1)
+		}
+		return object
+	}
+	return nil
+}
+
+// preferredMediaType returns the JSON media type of the content if it has one, or else the first in alphabetical order
+func preferredMediaType(content map[string]*MediaType) string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	for _, mediaType := range types {
+		if isJSON(mediaType) {
+			return mediaType
+		}
+	}
+	return types[0]
+}
+
+// isJSON reports whether the media type is JSON, such as application/json or application/problem+json
+func isJSON(mediaType string) bool {
+	mediaType = strings.ToLower(strings.TrimSpace(strings.SplitN(mediaType, ";", 2)[0]))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
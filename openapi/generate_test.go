@@ -0,0 +1,106 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/fercevik729/Wave/driver"
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	spec, err := Load("testdata/users.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, warnings, err := Generate(spec, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := "https://api.example.com/v1"
+	expected := []*driver.Request{{
+		Method:      "GET",
+		Base:        base,
+		Endpoint:    "/orgs/acme/tree/{id}",
+		IdRange:     []string{"3fa85f64-5717-4562-b3fc-2c963f66afa6"},
+		SuccessCode: 200,
+		Headers:     map[string]string{"X-Request-Id": "string"},
+		Auth:        &driver.AuthConfig{Type: "api-key", Header: "X-API-Key"},
+	}, {
+		Method:      "GET",
+		Base:        base,
+		Endpoint:    "/users?limit=10",
+		SuccessCode: 200,
+		Auth:        &driver.AuthConfig{Type: "bearer"},
+	}, {
+		Method:      "POST",
+		Base:        base,
+		Endpoint:    "/users",
+		SuccessCode: 201,
+		ContentType: "application/json",
+		Auth:        &driver.AuthConfig{Type: "bearer"},
+	}, {
+		Method:      "GET",
+		Base:        base,
+		Endpoint:    "/users/{id}",
+		IdRange:     []string{"42"},
+		SuccessCode: 200,
+		Auth:        &driver.AuthConfig{Type: "bearer"},
+	}, {
+		Method:      "DELETE",
+		Base:        base,
+		Endpoint:    "/users/{id}",
+		IdRange:     []string{"42"},
+		SuccessCode: 204,
+	}}
+	if len(imported) != len(expected) {
+		t.Fatalf("Expected %d requests, but got %d", len(expected), len(imported))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(imported[i].Request, expected[i]) {
+			t.Errorf("Expected %+v, but got %+v", expected[i], imported[i].Request)
+		}
+	}
+	if imported[2].Name != "createUser" || imported[4].Name != "delete /users/{userId}" {
+		t.Errorf("Expected requests named after their operation, but got %s and %s", imported[2].Name,
+			imported[4].Name)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Expected warnings about the bearer token and API key, but got %q", warnings)
+	}
+
+	// Example bodies
+	var body map[string]interface{}
+	if err := json.Unmarshal(imported[2].Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["name"] != "developer" || body["email"] != "developer45@gmail.com" {
+		t.Errorf("Expected the named example as the body, but got %s", imported[2].Body)
+	}
+
+	// Response schemas with their references resolved
+	var schema map[string]interface{}
+	if err := json.Unmarshal(imported[1].Schema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	items, _ := schema["items"].(map[string]interface{})
+	if schema["type"] != "array" || items == nil || items["type"] != "object" || items["$ref"] != nil {
+		t.Errorf("Expected the User schema to be inlined, but got %s", imported[1].Schema)
+	}
+	if imported[0].Schema == nil || imported[4].Schema != nil {
+		t.Errorf("Expected schemas only for JSON responses")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	if _, err := Parse([]byte("swagger: \"2.0\"\n")); err == nil {
+		t.Errorf("Expected an error for a Swagger 2.0 specification")
+	}
+	if _, err := Parse([]byte(`{"openapi": "3.0.0", "paths": {}}`)); err != nil {
+		t.Errorf("Expected JSON specifications to be parsed, but got %v", err)
+	}
+}
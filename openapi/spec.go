@@ -0,0 +1,394 @@
+/*
+Copyright © 2022 Furkan Ercevik ercevik.furkan@gmail.com
+
+*/
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Spec is the subset of an OpenAPI 3 specification used to generate and check requests
+type Spec struct {
+	OpenAPI    string                `yaml:"openapi" json:"openapi"`
+	Servers    []Server              `yaml:"servers" json:"servers"`
+	Paths      map[string]*PathItem  `yaml:"paths" json:"paths"`
+	Components Components            `yaml:"components" json:"components"`
+	Security   []map[string][]string `yaml:"security" json:"security"`
+}
+
+// Server is a server the API is available on, its URL may contain {variables}
+type Server struct {
+	URL       string `yaml:"url" json:"url"`
+	Variables map[string]struct {
+		Default string `yaml:"default" json:"default"`
+	} `yaml:"variables" json:"variables"`
+}
+
+// PathItem holds the operations of a path
+type PathItem struct {
+	Parameters []*Parameter `yaml:"parameters" json:"parameters"`
+	Get        *Operation   `yaml:"get" json:"get"`
+	Put        *Operation   `yaml:"put" json:"put"`
+	Post       *Operation   `yaml:"post" json:"post"`
+	Delete     *Operation   `yaml:"delete" json:"delete"`
+	Options    *Operation   `yaml:"options" json:"options"`
+	Head       *Operation   `yaml:"head" json:"head"`
+	Patch      *Operation   `yaml:"patch" json:"patch"`
+	Trace      *Operation   `yaml:"trace" json:"trace"`
+}
+
+// Operation is a single API operation on a path
+type Operation struct {
+	OperationID string                 `yaml:"operationId" json:"operationId"`
+	Summary     string                 `yaml:"summary" json:"summary"`
+	Parameters  []*Parameter           `yaml:"parameters" json:"parameters"`
+	RequestBody *RequestBody           `yaml:"requestBody" json:"requestBody"`
+	Responses   map[string]*Response   `yaml:"responses" json:"responses"`
+	Security    *[]map[string][]string `yaml:"security" json:"security"`
+}
+
+// Parameter is a path, query, header or cookie parameter of an operation
+type Parameter struct {
+	Ref      string              `yaml:"$ref" json:"$ref"`
+	Name     string              `yaml:"name" json:"name"`
+	In       string              `yaml:"in" json:"in"`
+	Required bool                `yaml:"required" json:"required"`
+	Schema   *Schema             `yaml:"schema" json:"schema"`
+	Example  interface{}         `yaml:"example" json:"example"`
+	Examples map[string]*Example `yaml:"examples" json:"examples"`
+}
+
+// RequestBody is the body of an operation by media type
+type RequestBody struct {
+	Ref      string                `yaml:"$ref" json:"$ref"`
+	Required bool                  `yaml:"required" json:"required"`
+	Content  map[string]*MediaType `yaml:"content" json:"content"`
+}
+
+// Response is a documented response of an operation
+type Response struct {
+	Ref         string                `yaml:"$ref" json:"$ref"`
+	Description string                `yaml:"description" json:"description"`
+	Content     map[string]*MediaType `yaml:"content" json:"content"`
+}
+
+// MediaType is the schema and examples of a body
+type MediaType struct {
+	Schema   *Schema             `yaml:"schema" json:"schema"`
+	Example  interface{}         `yaml:"example" json:"example"`
+	Examples map[string]*Example `yaml:"examples" json:"examples"`
+}
+
+// Example is a named example value
+type Example struct {
+	Ref   string      `yaml:"$ref" json:"$ref"`
+	Value interface{} `yaml:"value" json:"value"`
+}
+
+// Schema is an OpenAPI schema object. Its JSON form is the JSON schema written to schema files, without the example
+// annotations
+type Schema struct {
+	Ref                  string             `yaml:"$ref" json:"$ref,omitempty"`
+	Type                 string             `yaml:"type" json:"type,omitempty"`
+	Format               string             `yaml:"format" json:"format,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties" json:"properties,omitempty"`
+	Required             []string           `yaml:"required" json:"required,omitempty"`
+	AdditionalProperties interface{}        `yaml:"additionalProperties" json:"additionalProperties,omitempty"`
+	Items                *Schema            `yaml:"items" json:"items,omitempty"`
+	Enum                 []interface{}      `yaml:"enum" json:"enum,omitempty"`
+	Nullable             bool               `yaml:"nullable" json:"nullable,omitempty"`
+	AllOf                []*Schema          `yaml:"allOf" json:"allOf,omitempty"`
+	AnyOf                []*Schema          `yaml:"anyOf" json:"anyOf,omitempty"`
+	OneOf                []*Schema          `yaml:"oneOf" json:"oneOf,omitempty"`
+	Minimum              *float64           `yaml:"minimum" json:"minimum,omitempty"`
+	Maximum              *float64           `yaml:"maximum" json:"maximum,omitempty"`
+	MinLength            *int               `yaml:"minLength" json:"minLength,omitempty"`
+	MaxLength            *int               `yaml:"maxLength" json:"maxLength,omitempty"`
+	Pattern              string             `yaml:"pattern" json:"pattern,omitempty"`
+	MinItems             *int               `yaml:"minItems" json:"minItems,omitempty"`
+	MaxItems             *int               `yaml:"maxItems" json:"maxItems,omitempty"`
+	Example              interface{}        `yaml:"example" json:"-"`
+	Default              interface{}        `yaml:"default" json:"-"`
+}
+
+// Components holds the reusable objects referred to with $ref
+type Components struct {
+	Schemas         map[string]*Schema         `yaml:"schemas" json:"schemas"`
+	Parameters      map[string]*Parameter      `yaml:"parameters" json:"parameters"`
+	RequestBodies   map[string]*RequestBody    `yaml:"requestBodies" json:"requestBodies"`
+	Responses       map[string]*Response       `yaml:"responses" json:"responses"`
+	Examples        map[string]*Example        `yaml:"examples" json:"examples"`
+	SecuritySchemes map[string]*SecurityScheme `yaml:"securitySchemes" json:"securitySchemes"`
+}
+
+// SecurityScheme is a way of authenticating requests
+type SecurityScheme struct {
+	Type   string `yaml:"type" json:"type"`
+	Scheme string `yaml:"scheme" json:"scheme"`
+	In     string `yaml:"in" json:"in"`
+	Name   string `yaml:"name" json:"name"`
+}
+
+// Endpoint is an operation along with its path and method
+type Endpoint struct {
+	Method    string
+	Path      string
+	Operation *Operation
+	// Parameters are the parameters of the path and the operation with their references resolved
+	Parameters []*Parameter
+}
+
+// methods are the HTTP methods of a path item in the order their operations are listed
+var methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "TRACE"}
+
+// Load reads a YAML or JSON OpenAPI 3 specification
+func Load(filepath string) (*Spec, error) {
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses a YAML or JSON OpenAPI 3 specification
+func Parse(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, spec)
+	} else {
+		err = yaml.Unmarshal(data, spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse the OpenAPI specification: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, only OpenAPI 3 is supported", spec.OpenAPI)
+	}
+	return spec, nil
+}
+
+// Endpoints returns the operations of the specification sorted by path and method
+func (s *Spec) Endpoints() ([]*Endpoint, error) {
+	paths := make([]string, 0, len(s.Paths))
+	for path := range s.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var endpoints []*Endpoint
+	for _, path := range paths {
+		item := s.Paths[path]
+		if item == nil {
+			continue
+		}
+		operations := []*Operation{item.Get, item.Post, item.Put, item.Patch, item.Delete, item.Head, item.Options,
+			item.Trace}
+		for i, op := range operations {
+			if op == nil {
+				continue
+			}
+			params, err := s.parameters(item.Parameters, op.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", methods[i], path, err)
+			}
+			endpoints = append(endpoints, &Endpoint{Method: methods[i], Path: path, Operation: op, Parameters: params})
+		}
+	}
+	return endpoints, nil
+}
+
+// parameters resolves the parameters of a path and an operation, the operation parameters override the path ones
+func (s *Spec) parameters(pathParams, opParams []*Parameter) ([]*Parameter, error) {
+	var params []*Parameter
+	index := make(map[string]int)
+	for _, p := range append(append([]*Parameter{}, pathParams...), opParams...) {
+		if p.Ref != "" {
+			resolved, ok := s.Components.Parameters[refName(p.Ref, "parameters")]
+			if !ok {
+				return nil, fmt.Errorf("unresolved reference %s", p.Ref)
+			}
+			p = resolved
+		}
+		key := p.In + ":" + p.Name
+		if i, ok := index[key]; ok {
+			params[i] = p
+			continue
+		}
+		index[key] = len(params)
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// RequestBody resolves the request body of the operation
+func (s *Spec) RequestBody(op *Operation) (*RequestBody, error) {
+	body := op.RequestBody
+	if body != nil && body.Ref != "" {
+		resolved, ok := s.Components.RequestBodies[refName(body.Ref, "requestBodies")]
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %s", body.Ref)
+		}
+		body = resolved
+	}
+	return body, nil
+}
+
+// Response resolves a response of the operation
+func (s *Spec) Response(op *Operation, code string) (*Response, error) {
+	resp := op.Responses[code]
+	if resp != nil && resp.Ref != "" {
+		resolved, ok := s.Components.Responses[refName(resp.Ref, "responses")]
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %s", resp.Ref)
+		}
+		resp = resolved
+	}
+	return resp, nil
+}
+
+// ResolveSchema returns a copy of the schema with every reference replaced by the schema it refers to. Recursive
+// references are replaced by an empty schema accepting any value
+func (s *Spec) ResolveSchema(schema *Schema) (*Schema, error) {
+	return s.resolveSchema(schema, map[string]bool{})
+}
+
+func (s *Spec) resolveSchema(schema *Schema, seen map[string]bool) (*Schema, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	if schema.Ref != "" {
+		name := refName(schema.Ref, "schemas")
+		if seen[name] {
+			return &Schema{}, nil
+		}
+		resolved, ok := s.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %s", schema.Ref)
+		}
+		seen[name] = true
+		defer delete(seen, name)
+		return s.resolveSchema(resolved, seen)
+	}
+
+	copied := *schema
+	var err error
+	if copied.Items, err = s.resolveSchema(schema.Items, seen); err != nil {
+		return nil, err
+	}
+	if schema.Properties != nil {
+		copied.Properties = make(map[string]*Schema, len(schema.Properties))
+		for name, property := range schema.Properties {
+			if copied.Properties[name], err = s.resolveSchema(property, seen); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, list := range []*[]*Schema{&copied.AllOf, &copied.AnyOf, &copied.OneOf} {
+		resolved := make([]*Schema, len(*list))
+		for i, sub := range *list {
+			if resolved[i], err = s.resolveSchema(sub, seen); err != nil {
+				return nil, err
+			}
+		}
+		if len(resolved) > 0 {
+			*list = resolved
+		}
+	}
+	// additionalProperties is either a boolean or a schema
+	if additional := normalize(schema.AdditionalProperties); additional != nil {
+		if _, ok := additional.(bool); !ok {
+			raw, err := json.Marshal(additional)
+			if err != nil {
+				return nil, err
+			}
+			sub := &Schema{}
+			if err := json.Unmarshal(raw, sub); err != nil {
+				return nil, err
+			}
+			if additional, err = s.resolveSchema(sub, seen); err != nil {
+				return nil, err
+			}
+		}
+		copied.AdditionalProperties = additional
+	}
+	enum := make([]interface{}, len(schema.Enum))
+	for i, value := range schema.Enum {
+		enum[i] = normalize(value)
+	}
+	if len(enum) > 0 {
+		copied.Enum = enum
+	}
+	return &copied, nil
+}
+
+// SecurityScheme returns the first security scheme required by the operation, or nil if it needs none
+func (s *Spec) SecurityScheme(op *Operation) (string, *SecurityScheme) {
+	requirements := s.Security
+	if op.Security != nil {
+		requirements = *op.Security
+	}
+	for _, requirement := range requirements {
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if scheme, ok := s.Components.SecuritySchemes[name]; ok {
+				return name, scheme
+			}
+		}
+	}
+	return "", nil
+}
+
+// BaseURL returns the URL of the first server with its variables set to their defaults
+func (s *Spec) BaseURL() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	server := s.Servers[0]
+	url := server.URL
+	for name, variable := range server.Variables {
+		url = strings.ReplaceAll(url, "{"+name+"}", variable.Default)
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// refName returns the name of the component a local reference such as #/components/schemas/User refers to
+func refName(ref, kind string) string {
+	return strings.TrimPrefix(ref, "#/components/"+kind+"/")
+}
+
+// normalize converts the maps decoded from YAML into maps with string keys so that they can be written as JSON
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = normalize(item)
+		}
+		return list
+	}
+	return value
+}